@@ -0,0 +1,313 @@
+// Command evaluate runs a labelled corpus through scam detection the way the
+// server does (ScamDetection and IsScam per message, the session score over a
+// conversation, DetermineScamType on the result) and reports precision, recall
+// and F1, a confusion matrix by scam type and how often each rule fires on
+// scams and on other messages, as JSON. Given a baseline report it exits
+// non-zero when a score drops by more than the tolerance, so CI catches rule,
+// weight or model changes that make detection worse:
+//
+//	go run ./cmd/evaluate -corpus corpus/evaluation.jsonl -baseline corpus/baseline.json
+//
+// Write a new baseline with -out corpus/baseline.json once a change is known
+// to be an improvement.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/muskiteer/Ai-Scam/internal"
+)
+
+// notScam is the scam type of messages that are not, or are not detected as, scams
+const notScam = "none"
+
+// Report is the outcome of an evaluation
+type Report struct {
+	Corpus       string                    `json:"corpus"`
+	Items        int                       `json:"items"`
+	Messages     int                       `json:"messages"`
+	RulesVersion int                       `json:"rulesVersion"`
+	ModelVersion int                       `json:"modelVersion,omitempty"`
+	Detection    Scores                    `json:"detection"` // Scam or not
+	ScamTypes    map[string]Scores         `json:"scamTypes"` // Each scam type against the rest
+	Confusion    map[string]map[string]int `json:"confusion"` // Labelled type, then detected type
+	Rules        map[string]RuleStats      `json:"rules"`
+	Combinations map[string]int            `json:"combinations"` // Items decided by each combination
+	Errors       []Miss                    `json:"errors,omitempty"`
+}
+
+// Scores counts the outcomes of a binary decision
+type Scores struct {
+	TP        int     `json:"tp"`
+	FP        int     `json:"fp"`
+	FN        int     `json:"fn"`
+	TN        int     `json:"tn"`
+	Precision float64 `json:"precision"`
+	Recall    float64 `json:"recall"`
+	F1        float64 `json:"f1"`
+}
+
+// RuleStats counts the messages a rule fired on, by the label of their item
+type RuleStats struct {
+	ScamHits int     `json:"scamHits"`
+	HamHits  int     `json:"hamHits"`
+	ScamRate float64 `json:"scamRate"` // Share of scam messages hit
+	HamRate  float64 `json:"hamRate"`  // Share of other messages hit, false alarms
+}
+
+// Miss is an item labelled differently from what was detected
+type Miss struct {
+	Text     string `json:"text"`
+	Expected string `json:"expected"`
+	Got      string `json:"got"`
+	Score    int    `json:"score"` // Highest message score, or the session score
+}
+
+func main() {
+	corpus := flag.String("corpus", "corpus/evaluation.jsonl", "labelled JSONL corpus of messages and conversations")
+	out := flag.String("out", "", "write the report to this file instead of stdout")
+	baseline := flag.String("baseline", "", "earlier report to compare against")
+	tolerance := flag.Float64("tolerance", 0.02, "largest drop in precision, recall or F1 accepted against the baseline")
+	rulesFile := flag.String("rules", os.Getenv("DETECTION_RULES_FILE"), "detection rule file replacing the embedded one")
+	modelFile := flag.String("model", os.Getenv("CLASSIFIER_MODEL"), "classifier model replacing the embedded one")
+	flag.Parse()
+
+	os.Setenv("DETECTION_RULES_FILE", *rulesFile)
+	os.Setenv("CLASSIFIER_MODEL", *modelFile)
+	if err := internal.LoadRules(); err != nil {
+		fmt.Fprintf(os.Stderr, "rules: %v\n", err)
+		os.Exit(1)
+	}
+	if err := internal.LoadClassifier(); err != nil {
+		fmt.Fprintf(os.Stderr, "classifier: %v\n", err)
+		os.Exit(1)
+	}
+	items, err := internal.ReadCorpus(*corpus)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "corpus: %v\n", err)
+		os.Exit(1)
+	}
+
+	report := evaluate(items)
+	report.Corpus = *corpus
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "report: %v\n", err)
+		os.Exit(1)
+	}
+	data = append(data, '\n')
+	if *out == "" {
+		os.Stdout.Write(data)
+	} else if err := os.WriteFile(*out, data, 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "report: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *baseline != "" {
+		regressions, err := compare(report, *baseline, *tolerance)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "baseline: %v\n", err)
+			os.Exit(1)
+		}
+		for _, r := range regressions {
+			fmt.Fprintln(os.Stderr, "regression:", r)
+		}
+		if len(regressions) > 0 {
+			os.Exit(1)
+		}
+	}
+}
+
+// evaluate detects every item of the corpus
+func evaluate(items []internal.LabeledMessage) Report {
+	report := Report{
+		Items:        len(items),
+		ScamTypes:    map[string]Scores{},
+		Confusion:    map[string]map[string]int{},
+		Rules:        map[string]RuleStats{},
+		Combinations: map[string]int{},
+	}
+	rules := internal.GetRules()
+	report.RulesVersion = rules.Version
+	if model := internal.GetClassifier(); model != nil {
+		report.ModelVersion = model.Version
+	}
+	for _, r := range rules.Rules {
+		report.Rules[r.ID] = RuleStats{}
+	}
+
+	var scamMessages, hamMessages int
+	var types []string
+	for _, item := range items {
+		// The same steps as a session in StartConvo
+		session := &internal.SessionData{}
+		top := 0
+		combination := ""
+		for _, msg := range item.Turns() {
+			report.Messages++
+			if item.Scam {
+				scamMessages++
+			} else {
+				hamMessages++
+			}
+			indicators := internal.ScamIndicators{}
+			internal.ScamDetection(msg, &indicators)
+			session.AddMessage(msg)
+			for _, keyword := range indicators.Words {
+				session.AddKeyword(keyword)
+			}
+			session.Context.Intel = internal.MergeIntel(session.Context.Intel, internal.ExtractIntel(msg, indicators.Score))
+			session.Context.Score.Add(&indicators)
+			if trace := internal.Trace(&indicators); trace.Scam {
+				session.Context.ScamDetected = true
+				if combination == "" {
+					combination = trace.Combination
+				}
+			}
+			if indicators.Score > top {
+				top = indicators.Score
+			}
+			for _, hit := range indicators.Hits {
+				stats := report.Rules[hit.Rule]
+				if item.Scam {
+					stats.ScamHits++
+				} else {
+					stats.HamHits++
+				}
+				report.Rules[hit.Rule] = stats
+			}
+		}
+		if session.Context.Score.Detected {
+			session.Context.ScamDetected = true
+			if combination == "" {
+				combination = session.Context.Score.Combination
+			}
+		}
+		if len(item.Turns()) > 1 {
+			top = int(math.Round(session.Context.Score.Score))
+		}
+
+		expected, got := notScam, notScam
+		if item.Scam {
+			expected = item.ScamType
+			if expected == "" {
+				expected = "unknown"
+			}
+		}
+		if session.Context.ScamDetected {
+			got = internal.DetermineScamType(session)
+			report.Combinations[combination]++
+		}
+		if report.Confusion[expected] == nil {
+			report.Confusion[expected] = map[string]int{}
+		}
+		report.Confusion[expected][got]++
+		types = append(types, expected, got)
+
+		report.Detection.add(item.Scam, session.Context.ScamDetected, 1)
+		if expected != got {
+			report.Errors = append(report.Errors, Miss{
+				Text:     strings.Join(item.Turns(), " / "),
+				Expected: expected,
+				Got:      got,
+				Score:    top,
+			})
+		}
+	}
+	report.Detection.finish()
+
+	// Each scam type against the rest, from the confusion matrix
+	sort.Strings(types)
+	for _, t := range types {
+		if t == notScam {
+			continue
+		}
+		if _, ok := report.ScamTypes[t]; ok {
+			continue
+		}
+		var s Scores
+		for expected, row := range report.Confusion {
+			for got, n := range row {
+				s.add(expected == t, got == t, n)
+			}
+		}
+		s.finish()
+		report.ScamTypes[t] = s
+	}
+
+	for id, stats := range report.Rules {
+		stats.ScamRate = ratio(stats.ScamHits, scamMessages)
+		stats.HamRate = ratio(stats.HamHits, hamMessages)
+		report.Rules[id] = stats
+	}
+	return report
+}
+
+// add counts n items that should (want) and did (got) get the label
+func (s *Scores) add(want, got bool, n int) {
+	switch {
+	case want && got:
+		s.TP += n
+	case got:
+		s.FP += n
+	case want:
+		s.FN += n
+	default:
+		s.TN += n
+	}
+}
+
+func (s *Scores) finish() {
+	s.Precision = ratio(s.TP, s.TP+s.FP)
+	s.Recall = ratio(s.TP, s.TP+s.FN)
+	s.F1 = ratio(2*s.TP, 2*s.TP+s.FP+s.FN)
+}
+
+func ratio(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return round(float64(n) / float64(total))
+}
+
+func round(f float64) float64 {
+	return math.Round(f*1000) / 1000
+}
+
+// compare lists the scores of the report that fell by more than the tolerance
+// below the baseline's
+func compare(report Report, path string, tolerance float64) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var base Report
+	if err := json.Unmarshal(data, &base); err != nil {
+		return nil, err
+	}
+
+	var regressions []string
+	check := func(name string, was, now float64) {
+		if now < was-tolerance {
+			regressions = append(regressions, fmt.Sprintf("%s fell from %.3f to %.3f", name, was, now))
+		}
+	}
+	check("detection precision", base.Detection.Precision, report.Detection.Precision)
+	check("detection recall", base.Detection.Recall, report.Detection.Recall)
+	check("detection F1", base.Detection.F1, report.Detection.F1)
+	types := make([]string, 0, len(base.ScamTypes))
+	for t := range base.ScamTypes {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	for _, t := range types {
+		check(t+" F1", base.ScamTypes[t].F1, report.ScamTypes[t].F1)
+	}
+	return regressions, nil
+}
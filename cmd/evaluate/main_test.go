@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/muskiteer/Ai-Scam/internal"
+)
+
+var testCorpus = []internal.LabeledMessage{
+	{Text: "Sir your SBI YONO account is locked, share the code received to unlock it now", Scam: true, ScamType: "bank_fraud"},
+	{Text: "You have won the Flipkart mega lucky draw, pay 999 to claim your prize", Scam: true, ScamType: "lottery_fraud"},
+	{Text: "Your parcel could not be delivered, pay the customs fee at this link", Scam: true, ScamType: "lottery_fraud"}, // Detected as delivery_fraud
+	{Text: "Hi, how are you?", Scam: true, ScamType: "bank_fraud"},                                                        // Missed
+	{Text: "Your SBI account is blocked, share the OTP immediately", Scam: false},                                         // False alarm
+	{Text: "See you at dinner tonight", Scam: false},
+}
+
+func TestEvaluate(t *testing.T) {
+	report := evaluate(testCorpus)
+	if want := (Scores{TP: 3, FP: 1, FN: 1, TN: 1, Precision: 0.75, Recall: 0.75, F1: 0.75}); report.Detection != want {
+		t.Errorf("detection = %+v, want %+v", report.Detection, want)
+	}
+	if want := (Scores{TP: 1, FP: 0, FN: 1, TN: 4, Precision: 1, Recall: 0.5, F1: 0.667}); report.ScamTypes["lottery_fraud"] != want {
+		t.Errorf("lottery_fraud = %+v, want %+v", report.ScamTypes["lottery_fraud"], want)
+	}
+	if got := report.Confusion["lottery_fraud"]; got["lottery_fraud"] != 1 || got["delivery_fraud"] != 1 {
+		t.Errorf("lottery_fraud row = %v, want one lottery_fraud and one delivery_fraud", got)
+	}
+	if got := report.Confusion["bank_fraud"][notScam]; got != 1 {
+		t.Errorf("missed bank_fraud items = %d, want 1", got)
+	}
+	if report.Items != 6 || report.Messages != 6 || len(report.Errors) != 3 {
+		t.Errorf("%d items, %d messages, %d errors, want 6, 6, 3", report.Items, report.Messages, len(report.Errors))
+	}
+}
+
+func TestScores(t *testing.T) {
+	var s Scores
+	s.add(true, true, 6)
+	s.add(false, true, 2)
+	s.add(true, false, 3)
+	s.add(false, false, 9)
+	s.finish()
+	if want := (Scores{TP: 6, FP: 2, FN: 3, TN: 9, Precision: 0.75, Recall: 0.667, F1: 0.706}); s != want {
+		t.Errorf("scores = %+v, want %+v", s, want)
+	}
+	var empty Scores
+	empty.finish()
+	if empty.Precision != 0 || empty.Recall != 0 || empty.F1 != 0 {
+		t.Errorf("scores without items = %+v, want zeros", empty)
+	}
+}
+
+// writeBaseline saves a report with the given detection F1 and scam type F1s
+func writeBaseline(t *testing.T, f1 float64, types map[string]float64) string {
+	t.Helper()
+	base := Report{Detection: Scores{Precision: 0.75, Recall: 0.75, F1: f1}, ScamTypes: map[string]Scores{}}
+	for name, f1 := range types {
+		base.ScamTypes[name] = Scores{F1: f1}
+	}
+	data, _ := json.Marshal(base)
+	path := filepath.Join(t.TempDir(), "baseline.json")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCompare(t *testing.T) {
+	report := evaluate(testCorpus)
+	for _, tc := range []struct {
+		name      string
+		f1        float64
+		types     map[string]float64
+		tolerance float64
+		want      string
+	}{
+		{"unchanged", 0.75, map[string]float64{"lottery_fraud": 0.667}, 0.02, ""},
+		{"within tolerance", 0.76, map[string]float64{"lottery_fraud": 0.68}, 0.02, ""},
+		{"detection fell", 0.8, nil, 0.02, "detection F1 fell from 0.800 to 0.750"},
+		{"scam type fell", 0.75, map[string]float64{"lottery_fraud": 0.9}, 0.1, "lottery_fraud F1 fell from 0.900 to 0.667"},
+		{"scam type lost", 0.75, map[string]float64{"phishing": 0.5}, 0.02, "phishing F1 fell from 0.500 to 0.000"},
+		{"improvement", 0.5, map[string]float64{"lottery_fraud": 0.1}, 0, ""},
+	} {
+		got, err := compare(report, writeBaseline(t, tc.f1, tc.types), tc.tolerance)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if strings.Join(got, "; ") != tc.want {
+			t.Errorf("%s: regressions %q, want %q", tc.name, got, tc.want)
+		}
+	}
+	if _, err := compare(report, filepath.Join(t.TempDir(), "missing.json"), 0.02); err == nil {
+		t.Error("missing baseline accepted")
+	}
+}
+
+// TestMainFailsOnRegression runs the command in a child process, since it
+// reports a regression through its exit code
+func TestMainFailsOnRegression(t *testing.T) {
+	if os.Getenv("EVALUATE_TEST_ARGS") != "" {
+		os.Args = append([]string{"evaluate"}, strings.Split(os.Getenv("EVALUATE_TEST_ARGS"), " ")...)
+		main()
+		return
+	}
+
+	dir := t.TempDir()
+	corpus := filepath.Join(dir, "corpus.jsonl")
+	var lines []string
+	for _, item := range testCorpus {
+		line, _ := json.Marshal(item)
+		lines = append(lines, string(line))
+	}
+	if err := os.WriteFile(corpus, []byte(strings.Join(lines, "\n")), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	run := func(baseline string) error {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestMainFailsOnRegression$")
+		cmd.Env = append(os.Environ(), "EVALUATE_TEST_ARGS=-corpus "+corpus+" -out "+filepath.Join(dir, "report.json")+" -baseline "+baseline)
+		return cmd.Run()
+	}
+
+	if err := run(writeBaseline(t, 0.75, nil)); err != nil {
+		t.Errorf("run against an equal baseline failed: %v", err)
+	}
+	var exit *exec.ExitError
+	if err := run(writeBaseline(t, 0.9, nil)); !errors.As(err, &exit) || exit.ExitCode() != 1 {
+		t.Errorf("run against a better baseline: %v, want exit status 1", err)
+	}
+}
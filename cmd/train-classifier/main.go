@@ -0,0 +1,55 @@
+// Command train-classifier trains the naive Bayes scam classifier from a
+// labelled JSONL corpus, one {"text": ..., "scam": true|false} object per
+// line (a conversation takes "messages" instead of "text"), and writes the
+// model the server loads at startup. Training runs
+// offline and needs nothing but the corpus:
+//
+//	go run ./cmd/train-classifier -corpus corpus/messages.jsonl -out internal/classifier/model.json
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/muskiteer/Ai-Scam/internal"
+)
+
+func main() {
+	corpus := flag.String("corpus", "corpus/messages.jsonl", "labelled JSONL corpus")
+	out := flag.String("out", "internal/classifier/model.json", "model file to write; point CLASSIFIER_MODEL at it unless it is the embedded one")
+	minCount := flag.Int("min-count", 1, "drop tokens seen in fewer messages")
+	version := flag.Int("version", 1, "model version reported in detection traces")
+	flag.Parse()
+
+	messages, err := internal.ReadCorpus(*corpus)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "corpus: %v\n", err)
+		os.Exit(1)
+	}
+	model, err := internal.TrainClassifier(messages, *minCount, *version)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "training: %v\n", err)
+		os.Exit(1)
+	}
+	data, err := json.Marshal(model)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "model: %v\n", err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile(*out, append(data, '\n'), 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "model: %v\n", err)
+		os.Exit(1)
+	}
+
+	correct := 0
+	for _, msg := range messages {
+		if (model.Probability(strings.Join(msg.Turns(), "\n")) >= 0.5) == msg.Scam {
+			correct++
+		}
+	}
+	fmt.Printf("trained v%d on %d scam and %d ham messages, %d tokens, %.1f%% correct on the training corpus\n",
+		model.Version, model.Docs[1], model.Docs[0], len(model.Counts), 100*float64(correct)/float64(len(messages)))
+}
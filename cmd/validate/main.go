@@ -0,0 +1,58 @@
+// Command validate loads the prompt templates, the response catalogue, the
+// planning policy, the detection rules and the classifier model the same way
+// the server does and exits non-zero if any of them fails validation,
+// including the policy's scripted conversations and the rules' example
+// messages. Run it in CI, or before sending SIGHUP to a server with edited
+// files:
+//
+//	go run ./cmd/validate -prompts ./my-prompts -responses ./my-responses -policy ./policy.json -rules ./rules.json -model ./model.json
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/muskiteer/Ai-Scam/internal"
+)
+
+func main() {
+	promptDir := flag.String("prompts", os.Getenv("PROMPT_DIR"), "directory with prompt template overrides")
+	responseDir := flag.String("responses", os.Getenv("RESPONSE_CATALOGUE_DIR"), "directory with response catalogue overrides")
+	policyFile := flag.String("policy", os.Getenv("POLICY_FILE"), "planning policy file replacing the embedded one")
+	rulesFile := flag.String("rules", os.Getenv("DETECTION_RULES_FILE"), "detection rule file replacing the embedded one")
+	modelFile := flag.String("model", os.Getenv("CLASSIFIER_MODEL"), "classifier model replacing the embedded one")
+	flag.Parse()
+
+	os.Setenv("PROMPT_DIR", *promptDir)
+	os.Setenv("RESPONSE_CATALOGUE_DIR", *responseDir)
+	os.Setenv("POLICY_FILE", *policyFile)
+	os.Setenv("DETECTION_RULES_FILE", *rulesFile)
+	os.Setenv("CLASSIFIER_MODEL", *modelFile)
+
+	failed := false
+	if err := internal.LoadPrompts(); err != nil {
+		fmt.Fprintf(os.Stderr, "prompts: %v\n", err)
+		failed = true
+	}
+	if err := internal.LoadResponses(); err != nil {
+		fmt.Fprintf(os.Stderr, "responses: %v\n", err)
+		failed = true
+	}
+	if err := internal.LoadPolicies(); err != nil {
+		fmt.Fprintf(os.Stderr, "policies: %v\n", err)
+		failed = true
+	}
+	if err := internal.LoadRules(); err != nil {
+		fmt.Fprintf(os.Stderr, "rules: %v\n", err)
+		failed = true
+	}
+	if err := internal.LoadClassifier(); err != nil {
+		fmt.Fprintf(os.Stderr, "classifier: %v\n", err)
+		failed = true
+	}
+	if failed {
+		os.Exit(1)
+	}
+	fmt.Println("prompts, responses, policies, detection rules and the classifier are valid")
+}
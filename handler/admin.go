@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/muskiteer/Ai-Scam/internal"
+)
+
+// ReloadConfig reloads prompt templates and other file based configuration.
+// A component that fails validation keeps its previous version.
+func ReloadConfig(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !authorized(r) {
+		http.Error(w, "Unauthorized: Invalid or missing API key", http.StatusUnauthorized)
+		return
+	}
+
+	failed := internal.ReloadAll()
+	errs := map[string]string{}
+	for name, err := range failed {
+		errs[name] = err.Error()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if len(errs) > 0 {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(map[string]interface{}{"status": "error", "errors": errs})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
+
+// StrategyStats returns the learned intel yield of every intent and template
+// per scam type, best first
+func StrategyStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !authorized(r) {
+		http.Error(w, "Unauthorized: Invalid or missing API key", http.StatusUnauthorized)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(internal.GetStrategy().Report())
+}
+
+// Honeytokens returns the fake credentials handed out and where they were seen
+// again, for one session with ?sessionId= or for all of them
+func Honeytokens(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !authorized(r) {
+		http.Error(w, "Unauthorized: Invalid or missing API key", http.StatusUnauthorized)
+		return
+	}
+
+	report := internal.GetHoneytokens().Report()
+	if id := r.URL.Query().Get("sessionId"); id != "" {
+		report = internal.GetHoneytokens().ForSession(id)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// Canaries returns the canary links handed out and every hit on them, for one
+// session with ?sessionId= or for all of them
+func Canaries(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !authorized(r) {
+		http.Error(w, "Unauthorized: Invalid or missing API key", http.StatusUnauthorized)
+		return
+	}
+
+	report := internal.GetCanaries().Report()
+	if id := r.URL.Query().Get("sessionId"); id != "" {
+		report = internal.GetCanaries().ForSession(id)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
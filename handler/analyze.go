@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/muskiteer/Ai-Scam/internal"
+)
+
+// AnalyzeResponse explains how the detection rules judge a message
+type AnalyzeResponse struct {
+	Status  string                   `json:"status"`
+	Trace   internal.DetectionTrace  `json:"trace"`             // The message on its own
+	Session *internal.DetectionTrace `json:"session,omitempty"` // The scammer messages of the history and the message together
+}
+
+// Analyze runs scam detection on a message without engaging, so analysts can
+// see which rules matched where and which combination fired. It takes the
+// same body as /api/engage; the session ID is optional and no session is
+// created or changed.
+func Analyze(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !authorized(r) {
+		http.Error(w, "Unauthorized: Invalid or missing API key", http.StatusUnauthorized)
+		return
+	}
+
+	var request Request
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"status": "error", "reply": "Invalid request format"})
+		return
+	}
+	if request.Message.Text == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"status": "error", "reply": "message.text is required"})
+		return
+	}
+
+	indicators := internal.ScamIndicators{}
+	internal.ScamDetection(request.Message.Text, &indicators)
+	response := AnalyzeResponse{Status: "success", Trace: internal.Trace(&indicators)}
+	response.Trace.Message = request.Message.Text
+
+	var score internal.SessionScore
+	for _, msg := range request.ConvoHistory {
+		if internal.NormalizeSender(msg.Sender) == internal.SenderScammer {
+			histIndicators := internal.ScamIndicators{}
+			internal.ScamDetection(msg.Text, &histIndicators)
+			score.Add(&histIndicators)
+		}
+	}
+	if score.Messages > 0 {
+		score.Add(&indicators)
+		trace := score.Trace()
+		response.Session = &trace
+	}
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// analyze posts the message and history to Analyze
+func analyze(t *testing.T, text string, history ...MessageResponse) AnalyzeResponse {
+	t.Helper()
+	body, _ := json.Marshal(Request{Message: MessageResponse{Sender: "scammer", Text: text}, ConvoHistory: history})
+	rec := httptest.NewRecorder()
+	Analyze(rec, httptest.NewRequest(http.MethodPost, "/api/analyze", bytes.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("HTTP %d: %s", rec.Code, rec.Body)
+	}
+	var resp AnalyzeResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestAnalyzeTracesScammerHistoryOnly(t *testing.T) {
+	const text = "Your SBI account will be blocked today"
+	ours := MessageResponse{Sender: "user", Text: "Should I share the OTP urgently to unblock my bank account?"}
+	if resp := analyze(t, text, ours); resp.Session != nil {
+		t.Errorf("session trace built from our own replies: %+v", resp.Session)
+	}
+
+	theirs := MessageResponse{Sender: "scammer", Text: "Share the OTP immediately or pay the fine"}
+	resp := analyze(t, text, ours, theirs)
+	if resp.Session == nil {
+		t.Fatal("no session trace for scammer history")
+	}
+	if alone := analyze(t, text, theirs); resp.Session.Score != alone.Session.Score {
+		t.Errorf("session score %d with our replies, %d without", resp.Session.Score, alone.Session.Score)
+	}
+}
@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/muskiteer/Ai-Scam/internal"
+)
+
+// Canary serves the decoy behind a canary link (/c/{token}) and records who
+// opened it. It is public on purpose: the scammer is the one clicking.
+func Canary(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	canary, ok := internal.GetCanaries().Lookup(strings.TrimPrefix(r.URL.Path, "/c/"))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	headers := make(map[string]string, len(r.Header))
+	for name, values := range r.Header {
+		headers[name] = strings.Join(values, ", ")
+	}
+	hit := internal.GetCanaries().RecordHit(canary, clientIP(r), r.UserAgent(), headers)
+	log.Printf("Session %s - canary %s opened from %s (%s, preview %v)",
+		canary.SessionID, canary.Token, hit.IP, hit.UserAgent, hit.Preview)
+
+	body, contentType := internal.CanaryDecoy(canary)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Robots-Tag", "noindex")
+	if r.Method == http.MethodHead {
+		return
+	}
+	w.Write(body)
+}
+
+// clientIP returns the address of the client. X-Forwarded-For is only
+// believed when the request comes from a proxy listed in TRUSTED_PROXIES
+// (comma-separated IPs or CIDRs); then the client is the right-most hop that
+// is not one of those proxies, since anything to its left was written by the
+// client itself.
+func clientIP(r *http.Request) string {
+	remote, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		remote = r.RemoteAddr
+	}
+	trusted := trustedProxies()
+	if !isTrusted(remote, trusted) {
+		return remote
+	}
+	hops := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop != "" && !isTrusted(hop, trusted) {
+			return hop
+		}
+	}
+	if ip := strings.TrimSpace(r.Header.Get("X-Real-IP")); ip != "" {
+		return ip
+	}
+	return remote
+}
+
+// trustedProxies parses TRUSTED_PROXIES, skipping entries that are not an IP
+// or CIDR
+func trustedProxies() []*net.IPNet {
+	var nets []*net.IPNet
+	for _, entry := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			if ip := net.ParseIP(entry); ip != nil {
+				bits := 8 * net.IPv6len
+				if ip.To4() != nil {
+					ip, bits = ip.To4(), 8*net.IPv4len
+				}
+				nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			}
+			continue
+		}
+		if _, n, err := net.ParseCIDR(entry); err == nil {
+			nets = append(nets, n)
+		}
+	}
+	return nets
+}
+
+func isTrusted(addr string, trusted []*net.IPNet) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, n := range trusted {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
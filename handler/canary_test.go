@@ -0,0 +1,33 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	for _, tc := range []struct {
+		name, trusted, remote, forwarded, want string
+	}{
+		{"no proxies trusted", "", "203.0.113.7:5123", "1.2.3.4", "203.0.113.7"},
+		{"untrusted peer", "10.0.0.0/8", "203.0.113.7:5123", "1.2.3.4", "203.0.113.7"},
+		{"trusted proxy", "10.0.0.0/8", "10.0.0.5:443", "198.51.100.2", "198.51.100.2"},
+		{"spoofed left hop", "10.0.0.0/8", "10.0.0.5:443", "1.2.3.4, 198.51.100.2", "198.51.100.2"},
+		{"chain of proxies", "10.0.0.0/8, 192.0.2.1", "10.0.0.5:443", "198.51.100.2, 192.0.2.1, 10.1.1.1", "198.51.100.2"},
+		{"trusted proxy without header", "10.0.0.5", "10.0.0.5:443", "", "10.0.0.5"},
+		{"invalid entries skipped", "proxy.local, 10.0.0.5", "10.0.0.5:443", "198.51.100.2", "198.51.100.2"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("TRUSTED_PROXIES", tc.trusted)
+			r := httptest.NewRequest(http.MethodGet, "/c/token", nil)
+			r.RemoteAddr = tc.remote
+			if tc.forwarded != "" {
+				r.Header.Set("X-Forwarded-For", tc.forwarded)
+			}
+			if got := clientIP(r); got != tc.want {
+				t.Errorf("clientIP = %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
@@ -2,10 +2,12 @@ package handler
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
+	"math"
 	"net/http"
 	"os"
 	"strings"
@@ -35,22 +37,29 @@ type Metadata struct {
 }
 
 type Response struct {
-	Status string `json:"status"`
-	Reply  string `json:"reply"`
+	Status       string  `json:"status"`
+	Reply        string  `json:"reply"`
+	ScamDetected bool    `json:"scamDetected"`
+	Confidence   float64 `json:"confidence"` // Session scam confidence, 0–1
 }
 
 type ExtractedIntel struct {
-	BankAccounts       []string `json:"bankAccounts"`
-	UPIIds             []string `json:"upiIds"`
-	PhishingLinks      []string `json:"phishingLinks"`
-	PhoneNumbers       []string `json:"phoneNumbers"`
-	EmailAddresses     []string `json:"emailAddresses"`
-	CaseIDs            []string `json:"caseIDs,omitempty"`
-	PolicyNumbers      []string `json:"policyNumbers,omitempty"`
-	OrderNumbers       []string `json:"orderNumbers,omitempty"`
-	CardNumbers        []string `json:"cardNumbers,omitempty"`
-	IFSCCodes          []string `json:"ifscCodes,omitempty"`
-	SuspiciousKeywords []string `json:"suspiciousKeywords"`
+	BankAccounts       []string                   `json:"bankAccounts"`
+	UPIIds             []string                   `json:"upiIds"`
+	PhishingLinks      []string                   `json:"phishingLinks"`
+	PhoneNumbers       []string                   `json:"phoneNumbers"`
+	EmailAddresses     []string                   `json:"emailAddresses"`
+	CaseIDs            []string                   `json:"caseIDs,omitempty"`
+	PolicyNumbers      []string                   `json:"policyNumbers,omitempty"`
+	OrderNumbers       []string                   `json:"orderNumbers,omitempty"`
+	CardNumbers        []string                   `json:"cardNumbers,omitempty"`
+	IFSCCodes          []string                   `json:"ifscCodes,omitempty"`
+	OfficerIDs         []string                   `json:"officerIds,omitempty"`
+	SkypeIDs           []string                   `json:"skypeIds,omitempty"`
+	IntelLinks         []internal.IntelLink       `json:"intelLinks,omitempty"`
+	FallbackAccounts   []internal.FallbackAccount `json:"fallbackAccounts,omitempty"`
+	CanaryHits         []internal.CanaryHit       `json:"canaryHits,omitempty"`
+	SuspiciousKeywords []string                   `json:"suspiciousKeywords"`
 }
 
 type EngagementMetrics struct {
@@ -59,39 +68,50 @@ type EngagementMetrics struct {
 }
 
 type FinalResponse struct {
-	SessionID                 string            `json:"sessionId"`
-	ScamDetect                bool              `json:"scamDetected"`
-	TotalMessagesEx           int               `json:"totalMessagesExchanged"`
-	EngagementDurationSeconds int               `json:"engagementDurationSeconds"`
-	EngagementMetrics         EngagementMetrics `json:"engagementMetrics"`
-	ExtractIntel              ExtractedIntel    `json:"extractedIntelligence"`
-	AgentNote                 string            `json:"agentNotes"`
-	ScamType                  string            `json:"scamType,omitempty"`
-	ConfidenceLevel           string            `json:"confidenceLevel,omitempty"`
+	SessionID                 string                     `json:"sessionId"`
+	ScamDetect                bool                       `json:"scamDetected"`
+	TotalMessagesEx           int                        `json:"totalMessagesExchanged"`
+	EngagementDurationSeconds int                        `json:"engagementDurationSeconds"`
+	EngagementMetrics         EngagementMetrics          `json:"engagementMetrics"`
+	ExtractIntel              ExtractedIntel             `json:"extractedIntelligence"`
+	AgentNote                 string                     `json:"agentNotes"`
+	ScamType                  string                     `json:"scamType,omitempty"`
+	ConfidenceLevel           string                     `json:"confidenceLevel,omitempty"`
+	Confidence                float64                    `json:"confidence"`
+	Honeytokens               *internal.HoneytokenReport `json:"honeytokens,omitempty"`
+	RulesVersion              int                        `json:"detectionRulesVersion,omitempty"`
+	DetectionTraces           []internal.DetectionTrace  `json:"detectionTraces,omitempty"`  // Per scammer message
+	SessionDetection          *internal.DetectionTrace   `json:"sessionDetection,omitempty"` // On the session score
 }
 
 const SCAM_THRESHOLD = 50
 
+// turnDelay is the least time a turn of StartConvo takes
+var turnDelay = 12 * time.Second
+
 func HealthCheck(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("OK"))
 }
 
+// authorized checks the x-api-key header when API_KEY is configured
+func authorized(r *http.Request) bool {
+	apiKey := os.Getenv("API_KEY")
+	return apiKey == "" || r.Header.Get("x-api-key") == apiKey
+}
+
 func StartConvo(w http.ResponseWriter, r *http.Request) {
 
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
+	start := time.Now()
 
-	apiKey := os.Getenv("API_KEY")
-	if apiKey != "" {
-		requestKey := r.Header.Get("x-api-key")
-		if requestKey != apiKey {
-			http.Error(w, "Unauthorized: Invalid or missing API key", http.StatusUnauthorized)
-			return
-		}
+	if !authorized(r) {
+		http.Error(w, "Unauthorized: Invalid or missing API key", http.StatusUnauthorized)
+		return
 	}
 	// body, _ := io.ReadAll(r.Body)
 	// log.Println("Received request body: ", string(body))
@@ -120,20 +140,64 @@ func StartConvo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get or create session
+	// Get or create session. A session whose final callback went out is
+	// closed, also for requests that were waiting for its last turn.
 	store := internal.GetStore()
+	if store.Finished(request.SessionID) {
+		rejectFinished(w, request.SessionID)
+		return
+	}
 	session := store.Get(request.SessionID)
+	session.BeginTurn()
+	turnEnded := false
+	defer func() {
+		if !turnEnded {
+			session.EndTurn()
+		}
+	}()
+	if store.Finished(request.SessionID) {
+		rejectFinished(w, request.SessionID)
+		return
+	}
 
-	// Add incoming message to history
+	// Restore earlier turns from the platform if this session is new to us,
+	// then add the incoming message to history
+	session.SeedTranscript(transcriptFromRequest(request.ConvoHistory))
 	session.AddMessage(request.Message.Text)
 	session.Context.TurnCount++
 
+	// A fake credential handed out earlier coming back links this scammer to that session
+	for _, s := range internal.GetHoneytokens().Scan(request.SessionID, session.Context.TurnCount, request.Message.Text) {
+		log.Printf("Session %s - honeytoken %s %s from session %s seen (linked %v)",
+			request.SessionID, s.Token.Kind, s.Token.Value, s.Token.SessionID, s.Linked)
+	}
+
 	// Run scam detection
 	indicators := internal.ScamIndicators{}
 	internal.ScamDetection(request.Message.Text, &indicators)
+	session.Context.RulesVersion = indicators.RulesVersion
+
+	// Fold the message into the session score. A session we pick up mid-way,
+	// e.g. after a restart, first scores the scammer messages of the history.
+	if session.Context.Score.Messages == 0 {
+		for _, msg := range request.ConvoHistory {
+			if internal.NormalizeSender(msg.Sender) == internal.SenderScammer {
+				histIndicators := internal.ScamIndicators{}
+				internal.ScamDetection(msg.Text, &histIndicators)
+				session.Context.Score.Add(&histIndicators)
+			}
+		}
+	}
+	session.Context.Score.Add(&indicators)
+	if len(indicators.Hits) > 0 {
+		trace := internal.Trace(&indicators)
+		trace.Turn, trace.Message = session.Context.TurnCount, request.Message.Text
+		session.Context.Traces = append(session.Context.Traces, trace)
+	}
 
-	// Update scam detection status using combination logic
-	if internal.IsScam(&indicators) {
+	// Update scam detection status using combination logic, on the message
+	// alone and on the evidence of the whole session
+	if internal.IsScam(&indicators) || session.Context.Score.Detected {
 		session.Context.ScamDetected = true
 	}
 
@@ -150,13 +214,16 @@ func StartConvo(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Extract intelligence from current message
-	newIntel := internal.ExtractIntel(request.Message.Text, indicators.Score)
+	knownIntel := session.Context.Intel
+	intelBefore := knownIntel.Count()
+	honeytokens := internal.GetHoneytokens()
+	newIntel := extractIntel(request.Message.Text, indicators.Score)
 	session.Context.Intel = internal.MergeIntel(session.Context.Intel, newIntel)
 
 	// Also scan conversation history for any missed intel
 	for _, msg := range request.ConvoHistory {
 		if msg.Sender == "scammer" || msg.Sender == "user" {
-			histIntel := internal.ExtractIntel(msg.Text, indicators.Score)
+			histIntel := extractIntel(msg.Text, indicators.Score)
 			session.Context.Intel = internal.MergeIntel(session.Context.Intel, histIntel)
 			// Also run scam detection on history
 			histIndicators := internal.ScamIndicators{}
@@ -173,6 +240,44 @@ func StartConvo(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// Link the answer to last turn's read-back and keep our deliberate mistakes out of the intel
+	session.Context.Intel = internal.ResolveReadBack(session.Context.Intel, session.Context.PendingReadBack,
+		request.Message.Text, newIntel, session.Context.TurnCount)
+	session.Context.PendingReadBack = nil
+
+	// Reward the previous reply's intent and template if this message brought new intel
+	if choice := session.Context.PendingChoice; choice != nil {
+		internal.GetStrategy().Record(*choice, session.Context.Intel.Count() > intelBefore)
+		session.Context.PendingChoice = nil
+	}
+
+	// Flag attempts to steer the LLM instead of the victim
+	injection := internal.DetectInjection(request.Message.Text)
+	if injection.Risk != internal.InjectionRiskNone {
+		log.Printf("Session %s - prompt injection suspected (%s, score %d): %v",
+			request.SessionID, injection.Risk, injection.Score, injection.Matches)
+		session.Context.InjectionAttempts++
+		if !containsString(session.Context.RedFlagsIdentified, internal.RedFlagPromptInjection) {
+			session.Context.RedFlagsIdentified = append(session.Context.RedFlagsIdentified, internal.RedFlagPromptInjection)
+		}
+	}
+
+	// Remember who the scammer claims to be
+	if session.Context.ClaimedOrg == "" {
+		session.Context.ClaimedOrg = internal.ExtractClaimedOrg(request.Message.Text)
+	}
+
+	// Work out what the scammer is demanding so the reply can react to it
+	acts := internal.ClassifyDialogueAct(request.Message.Text)
+	for _, act := range acts.Acts {
+		session.Context.ActCounts[act]++
+	}
+	session.Context.LastAct = acts.Primary
+	log.Printf("Session %s - Dialogue acts: %v", request.SessionID, acts.Acts)
+
+	// Answer in the language and script the scammer is using
+	session.Locale = internal.ResolveLocale(request.Metadata.Language, request.Metadata.Locale, request.Message.Text)
+
 	// Log current intel status
 	log.Printf("Session %s - Turn %d - Intel: UPI=%d, Phone=%d, Link=%d, Bank=%d, Email=%d",
 		request.SessionID, session.Context.TurnCount,
@@ -180,124 +285,216 @@ func StartConvo(w http.ResponseWriter, r *http.Request) {
 		len(session.Context.Intel.Link), len(session.Context.Intel.Bank),
 		len(session.Context.Intel.Email))
 
+	// Tag accounts offered after a "failed" transfer and move the sub-flow on
+	scamType := internal.DetermineScamType(session)
+	if policies := internal.GetPolicies(); policies != nil {
+		session.Context.Payment.Advance(policies.FailedPayment(scamType), knownIntel,
+			internal.DropReadBacks(newIntel, session.Context.Intel.Links), acts.Primary, session.Context.TurnCount)
+	}
+
 	// Update state based on context
 	session.Context.CurrentState = internal.GetState(session.Context)
 	log.Printf("Session %s - Current State: %s", request.SessionID, session.Context.CurrentState)
 
-	// Derive intent for response
-	intent := internal.DeriveIntent(
-		session.Context.CurrentState,
-		session.Context.Intel,
-		session.Context.TurnCount,
-		session.Context.AskCount,
-	)
+	// Derive intent for response from the planning policy for this scam type
+	intent := internal.DeriveIntent(scamType, session.Context, acts.Primary, session.Selection.Rand)
+	session.Context.LastIntent = intent
 
 	// Increment ask count based on intent
-	switch intent {
-	case internal.IntentAskUPI:
-		session.Context.AskCount.UPI++
-		session.Context.QuestionsAsked++
-		session.Context.InvestigativeQuestions++
-		session.Context.InformationElicitations++
-	case internal.IntentAskPhone:
-		session.Context.AskCount.Phone++
-		session.Context.QuestionsAsked++
-		session.Context.InvestigativeQuestions++
-		session.Context.InformationElicitations++
-	case internal.IntentAskLink:
-		session.Context.AskCount.Link++
+	if session.Context.AskCount.Record(intent) {
 		session.Context.QuestionsAsked++
 		session.Context.InvestigativeQuestions++
 		session.Context.InformationElicitations++
-	case internal.IntentAskBank:
-		session.Context.AskCount.Bank++
-		session.Context.QuestionsAsked++
-		session.Context.InvestigativeQuestions++
-		session.Context.InformationElicitations++
-	case internal.IntentAskEmail:
-		session.Context.AskCount.Email++
-		session.Context.QuestionsAsked++
-		session.Context.InvestigativeQuestions++
-		session.Context.InformationElicitations++
-	case internal.IntentAskCaseID:
-		session.Context.AskCount.CaseID++
+	}
+	switch intent {
+	case internal.IntentAskIdentity:
 		session.Context.QuestionsAsked++
 		session.Context.InvestigativeQuestions++
-		session.Context.InformationElicitations++
-	case internal.IntentAskPolicyNumber:
-		session.Context.AskCount.PolicyNumber++
+	case internal.IntentDeepProbe:
 		session.Context.QuestionsAsked++
 		session.Context.InvestigativeQuestions++
 		session.Context.InformationElicitations++
-	case internal.IntentAskOrderNumber:
-		session.Context.AskCount.OrderNumber++
+	case internal.IntentConfirmDetails:
 		session.Context.QuestionsAsked++
 		session.Context.InvestigativeQuestions++
-		session.Context.InformationElicitations++
-	case internal.IntentAskCardNumber:
-		session.Context.AskCount.CardNumber++
+	case internal.IntentDeflectCredential, internal.IntentReassure, internal.IntentGiveHoneytoken, internal.IntentSendCanary:
 		session.Context.QuestionsAsked++
-		session.Context.InvestigativeQuestions++
-		session.Context.InformationElicitations++
-	case internal.IntentAskIFSCCode:
-		session.Context.AskCount.IFSCCode++
+	case internal.IntentReadBack, internal.IntentAskBackupAccount:
 		session.Context.QuestionsAsked++
-		session.Context.InvestigativeQuestions++
 		session.Context.InformationElicitations++
-	case internal.IntentAskIdentity:
-		session.Context.QuestionsAsked++
-		session.Context.InvestigativeQuestions++
-	case internal.IntentDeepProbe:
+	case internal.IntentReportPaymentFailure:
 		session.Context.QuestionsAsked++
-		session.Context.InvestigativeQuestions++
 		session.Context.InformationElicitations++
-	case internal.IntentConfirmDetails:
-		session.Context.QuestionsAsked++
-		session.Context.InvestigativeQuestions++
+		if target, ok := session.Context.Payment.NextPaymentTarget(session.Context.Intel); ok {
+			session.Context.Payment.Start(target)
+		}
 	}
 
-	reply := internal.GetResponse(intent)
+	// Pick the identifier to read back with a deliberate mistake
+	var readBack *internal.ReadBack
+	if intent == internal.IntentReadBack {
+		if rb, ok := internal.NextReadBack(session.Context.Intel, session.Selection.Rand); ok {
+			rb.Turn = session.Context.TurnCount
+			readBack = &rb
+		}
+	}
+
+	// Mint fake values of what the scammer is demanding
+	var minted []internal.Honeytoken
+	if intent == internal.IntentGiveHoneytoken {
+		minted = honeytokens.Mint(internal.HoneytokenKinds(request.Message.Text), session.Persona,
+			request.Message.Text, session.Selection.Rand)
+	}
+
+	// Send "proof of payment" as a link that reports who opens it
+	var canary *internal.Canary
+	if intent == internal.IntentSendCanary {
+		if c, ok := internal.GetCanaries().Mint(internal.CanaryKindFor(request.Message.Text)); ok {
+			canary = &c
+		}
+	}
+
+	choice := internal.StrategyChoice{ScamType: scamType, Intent: intent}
+	reply := generateReply(r.Context(), internal.ResponseRequest{
+		SessionID:      request.SessionID,
+		Intent:         intent,
+		ScammerMessage: request.Message.Text,
+		History:        session.Transcript[:len(session.Transcript)-1],
+		TurnCount:      session.Context.TurnCount,
+		ScamType:       scamType,
+		ClaimedOrg:     session.Context.ClaimedOrg,
+		Intel:          session.Context.Intel,
+		InjectionRisk:  injection.Risk,
+		Persona:        session.Persona,
+		Facts:          &session.Facts,
+		Locale:         session.Locale,
+		Selection:      &session.Selection,
+		Act:            acts.Primary,
+		Choice:         &choice,
+		ReadBack:       readBack,
+		PaymentTarget:  session.Context.Payment.Target,
+		Honeytokens:    minted,
+		Canary:         canary,
+	})
 	log.Println("reply: ", reply)
+	session.AddReply(reply)
+	session.Facts.Record(reply, session.Context.TurnCount, "agent")
+	if issued := honeytokens.Issue(request.SessionID, session.Context.TurnCount, minted, reply); len(issued) > 0 {
+		log.Printf("Session %s - handed over %d honeytoken(s)", request.SessionID, len(issued))
+	}
+	if internal.GetCanaries().Issue(request.SessionID, session.Context.TurnCount, canary, reply) {
+		log.Printf("Session %s - sent canary %s", request.SessionID, canary.URL)
+	}
+	if choice.Template == "" {
+		choice.Template = internal.TemplateLLM
+	}
+	session.Context.PendingChoice = &choice
+	session.Context.PendingReadBack = readBack
 
-	// Delay for engagement duration scoring (stays well within 30s API timeout)
-	// 15 turns x ~14s = ~210+ seconds total engagement
-	time.Sleep(12 * time.Second)
+	// The reply goes out once the engagement delay below has passed
+	replyAt := start.Add(turnDelay)
+	if now := time.Now(); now.After(replyAt) {
+		replyAt = now
+	}
 
 	// At turn 10: fire an intermediate callback WITHOUT ending the session.
 	// This guarantees a score even if the evaluator stops at exactly turn 10.
+	// Reports are built here, under the turn lock, since later turns keep
+	// changing the session while the callback is sent.
+	var reports [][]byte
 	if session.Context.TurnCount == 10 {
 		log.Printf("Session %s - Turn 10: sending intermediate callback, session continues.",
 			request.SessionID)
-		sessionSnapshot := *session
-		go sendFinalCallback(&sessionSnapshot)
+		if report, err := buildFinalReport(session, replyAt); err == nil {
+			reports = append(reports, report)
+		} else {
+			log.Printf("Error marshaling final report: %v", err)
+		}
 	}
 
 	// At turn 15 (or beyond): fire final enriched callback and close session.
 	if session.Context.TurnCount >= 15 {
 		log.Printf("Session %s - Turn 15: sending final callback and closing session.",
 			request.SessionID)
-		go sendFinalCallback(session)
-		store.Delete(request.SessionID)
+		if report, err := buildFinalReport(session, replyAt); err == nil {
+			reports = append(reports, report)
+		} else {
+			log.Printf("Error marshaling final report: %v", err)
+		}
+		store.Finish(request.SessionID)
 	} else {
 		store.Update(session)
 	}
 
 	response := Response{
-		Status: "success",
-		Reply:  reply,
+		Status:       "success",
+		Reply:        reply,
+		ScamDetected: session.Context.ScamDetected,
+		Confidence:   roundConfidence(session.Context.Score.Confidence),
+	}
+
+	// Delay for engagement duration scoring (stays well within 30s API timeout)
+	// 15 turns x ~14s = ~210+ seconds total engagement
+	// Time already spent generating the reply counts towards the delay. The
+	// session is released first so that a concurrent turn of it does not wait
+	// out this delay on top of its own.
+	session.EndTurn()
+	turnEnded = true
+	time.Sleep(time.Until(replyAt))
+	for _, report := range reports {
+		go sendFinalCallback(request.SessionID, report)
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
-func sendFinalCallback(session *internal.SessionData) {
+// rejectFinished answers a turn for a session that has already been closed
+func rejectFinished(w http.ResponseWriter, sessionID string) {
+	log.Printf("Session %s - turn after the final callback rejected", sessionID)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusConflict)
+	json.NewEncoder(w).Encode(map[string]string{"status": "error", "reply": "session already finished"})
+}
 
-	callbackURL := os.Getenv("CALLBACK_URL")
-	if callbackURL == "" {
-		callbackURL = "https://hackathon.guvi.in/api/updateHoneyPotFinalResult"
-		log.Printf("Using default GUVI callback endpoint: %s", callbackURL)
+// generateReply asks the configured responder for a reply and falls back to
+// the static templates on error or timeout
+func generateReply(ctx context.Context, req internal.ResponseRequest) string {
+	reply, err := internal.GetResponder().Respond(ctx, req)
+	if err != nil || strings.TrimSpace(reply) == "" {
+		log.Printf("Session %s - responder failed, using template reply: %v", req.SessionID, err)
+		reply, _ = internal.TemplateResponder{}.Respond(ctx, req)
+		return reply
+	}
+	return reply
+}
+
+// extractIntel extracts intel from a message, leaving out the honeytokens and
+// canary links we handed out ourselves
+func extractIntel(text string, confidence int) internal.Intel {
+	intel := internal.ExtractIntel(text, confidence)
+	return internal.GetCanaries().Filter(internal.GetHoneytokens().Filter(intel))
+}
+
+// transcriptFromRequest converts the platform supplied history into sender-tagged turns
+func transcriptFromRequest(history []MessageResponse) []internal.ChatTurn {
+	turns := make([]internal.ChatTurn, 0, len(history))
+	for _, msg := range history {
+		if strings.TrimSpace(msg.Text) == "" {
+			continue
+		}
+		turns = append(turns, internal.ChatTurn{
+			Sender: internal.NormalizeSender(msg.Sender),
+			Text:   msg.Text,
+		})
 	}
+	return turns
+}
+
+// buildFinalReport marshals the final intelligence report of the session as
+// of the reply sent at replyAt. It must run while the caller holds the
+// session's turn.
+func buildFinalReport(session *internal.SessionData, replyAt time.Time) ([]byte, error) {
 
 	notes := buildAgentNotes(session)
 
@@ -305,14 +502,19 @@ func sendFinalCallback(session *internal.SessionData) {
 	totalMessages := session.Context.TurnCount * 2
 
 	// Calculate engagement duration in seconds using session start time
-	engagementDuration := int(time.Since(session.StartTime).Seconds())
+	engagementDuration := int(replyAt.Sub(session.StartTime).Seconds())
 
 	// Determine scam type based on keywords and indicators
-	scamType := determineScamType(session)
+	scamType := internal.DetermineScamType(session)
 
 	// Determine confidence level
 	confidenceLevel := determineConfidenceLevel(session)
 
+	var honeytokens *internal.HoneytokenReport
+	if report := internal.GetHoneytokens().ForSession(session.SessionID); len(report.Issued) > 0 || len(report.Sightings) > 0 {
+		honeytokens = &report
+	}
+
 	finalReport := FinalResponse{
 		SessionID:                 session.SessionID,
 		ScamDetect:                session.Context.ScamDetected,
@@ -333,18 +535,38 @@ func sendFinalCallback(session *internal.SessionData) {
 			OrderNumbers:       session.Context.Intel.OrderNumbers,
 			CardNumbers:        session.Context.Intel.CardNumbers,
 			IFSCCodes:          session.Context.Intel.IFSCCodes,
+			OfficerIDs:         session.Context.Intel.OfficerIDs,
+			SkypeIDs:           session.Context.Intel.SkypeIDs,
+			IntelLinks:         session.Context.Intel.Links,
+			FallbackAccounts:   session.Context.Payment.Fallbacks,
+			CanaryHits:         internal.GetCanaries().ForSession(session.SessionID).Hits,
 			SuspiciousKeywords: session.Keywords,
 		},
 		AgentNote:       notes,
 		ScamType:        scamType,
 		ConfidenceLevel: confidenceLevel,
+		Confidence:      roundConfidence(session.Context.Score.Confidence),
+		Honeytokens:     honeytokens,
+		RulesVersion:    session.Context.RulesVersion,
+		DetectionTraces: session.Context.Traces,
+	}
+	if session.Context.Score.Messages > 0 {
+		trace := session.Context.Score.Trace()
+		finalReport.SessionDetection = &trace
 	}
 
-	jsonData, err := json.Marshal(finalReport)
-	if err != nil {
-		log.Printf("Error marshaling final report: %v", err)
-		return
+	return json.Marshal(finalReport)
+}
+
+// sendFinalCallback posts a report built by buildFinalReport
+func sendFinalCallback(sessionID string, jsonData []byte) {
+
+	callbackURL := os.Getenv("CALLBACK_URL")
+	if callbackURL == "" {
+		callbackURL = "https://hackathon.guvi.in/api/updateHoneyPotFinalResult"
+		log.Printf("Using default GUVI callback endpoint: %s", callbackURL)
 	}
+
 	log.Println("+_+_+_+_+_+_+_+_+_+_+_+_+_+_+_+_+_+_+_+_+_+_+_+_+_+_+_+_+_+_+")
 	log.Println("Final report JSON: ", string(jsonData))
 
@@ -356,9 +578,9 @@ func sendFinalCallback(session *internal.SessionData) {
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-		log.Printf("Final callback sent successfully for session %s", session.SessionID)
+		log.Printf("Final callback sent successfully for session %s", sessionID)
 	} else {
-		log.Printf("Callback failed with status %d for session %s", resp.StatusCode, session.SessionID)
+		log.Printf("Callback failed with status %d for session %s", resp.StatusCode, sessionID)
 	}
 }
 
@@ -466,12 +688,76 @@ func buildAgentNotes(session *internal.SessionData) string {
 	if len(session.Context.Intel.OrderNumbers) > 0 {
 		intelItems = append(intelItems, "Order: "+strings.Join(session.Context.Intel.OrderNumbers, ", "))
 	}
+	if len(session.Context.Intel.OfficerIDs) > 0 {
+		intelItems = append(intelItems, "OfficerID: "+strings.Join(session.Context.Intel.OfficerIDs, ", "))
+	}
+	if len(session.Context.Intel.SkypeIDs) > 0 {
+		intelItems = append(intelItems, "Skype: "+strings.Join(session.Context.Intel.SkypeIDs, ", "))
+	}
 	if len(intelItems) > 0 {
 		parts = append(parts, "EXTRACTED INTEL: "+strings.Join(intelItems, " | "))
 	} else {
 		parts = append(parts, "INTEL STATUS: Scammer withheld all identifying information despite repeated probing attempts.")
 	}
 
+	if session.Context.InjectionAttempts > 0 {
+		parts = append(parts, fmt.Sprintf("PROMPT INJECTION: %d message(s) tried to manipulate the automated responder",
+			session.Context.InjectionAttempts))
+	}
+
+	if n := session.Context.ActCounts[internal.ActRequestCredential]; n > 0 {
+		parts = append(parts, fmt.Sprintf("CREDENTIAL DEMANDS: Scammer asked for OTP/PIN/password %d time(s); no real credentials were given", n))
+	}
+	if n := session.Context.ActCounts[internal.ActRequestPayment]; n > 0 {
+		parts = append(parts, fmt.Sprintf("PAYMENT DEMANDS: Scammer asked for money %d time(s)", n))
+	}
+	if len(session.Context.Intel.Links) > 0 {
+		var readBacks []string
+		for _, link := range session.Context.Intel.Links {
+			item := link.Kind + " " + link.Original + " read back as " + link.ReadBack + ": " + link.Relation
+			if link.Value != "" && link.Value != link.Original {
+				item += " " + link.Value
+			}
+			readBacks = append(readBacks, item)
+		}
+		parts = append(parts, "READ-BACKS: "+strings.Join(readBacks, " | "))
+	}
+	honeytokens := internal.GetHoneytokens().ForSession(session.SessionID)
+	if len(honeytokens.Issued) > 0 {
+		var issued []string
+		for _, t := range honeytokens.Issued {
+			issued = append(issued, t.Kind+" "+t.Value)
+		}
+		parts = append(parts, "HONEYTOKENS HANDED OVER: "+strings.Join(issued, " | "))
+	}
+	if len(honeytokens.LinkedSessions) > 0 {
+		var links []string
+		for _, s := range honeytokens.Sightings {
+			if s.Linked {
+				links = append(links, fmt.Sprintf("%s %s issued in %s seen in %s", s.Token.Kind, s.Token.Value, s.Token.SessionID, s.SeenIn))
+			}
+		}
+		parts = append(parts, "LINKED CAMPAIGNS: "+strings.Join(links, " | "))
+	}
+	if hits := internal.GetCanaries().ForSession(session.SessionID).Hits; len(hits) > 0 {
+		var clicks []string
+		for _, hit := range hits {
+			item := hit.Kind + " opened from " + hit.IP + " (" + hit.UserAgent + ")"
+			if hit.Preview {
+				item += " [link preview]"
+			}
+			clicks = append(clicks, item)
+		}
+		parts = append(parts, "CANARY CLICKS: "+strings.Join(clicks, " | "))
+	}
+	if len(session.Context.Payment.Fallbacks) > 0 {
+		var fallbacks []string
+		for _, f := range session.Context.Payment.Fallbacks {
+			fallbacks = append(fallbacks, f.Kind+" "+f.Value+" (after "+f.FailedFor+" failed)")
+		}
+		parts = append(parts, "FALLBACK ACCOUNTS: "+strings.Join(fallbacks, " | "))
+	}
+
 	// Tactics and keywords observed
 	if len(session.Keywords) > 0 {
 		parts = append(parts, "SCAMMER TACTICS: "+strings.Join(deduplicateStrings(session.Keywords), ", "))
@@ -487,7 +773,7 @@ func buildAgentNotes(session *internal.SessionData) string {
 	))
 
 	// Threat classification
-	scamType := determineScamType(session)
+	scamType := internal.DetermineScamType(session)
 	if scamType != "unknown" {
 		parts = append(parts, fmt.Sprintf("THREAT CLASS: %s — matches known fraud methodology targeting Indian users", scamType))
 	}
@@ -495,80 +781,13 @@ func buildAgentNotes(session *internal.SessionData) string {
 	return strings.Join(parts, " | ")
 }
 
-func determineScamType(session *internal.SessionData) string {
-	// Scan ALL text: keywords + full conversation history
-	allText := strings.ToLower(strings.Join(session.Keywords, " ") + " " + strings.Join(session.MessageHistory, " "))
-
-	// Government/legal threat (highest priority — very distinct pattern)
-	if strings.Contains(allText, "police") || strings.Contains(allText, "arrest") ||
-		strings.Contains(allText, "cbi") || strings.Contains(allText, "warrant") ||
-		strings.Contains(allText, "court") || strings.Contains(allText, "legal action") {
-		return "govt_threat_fraud"
-	}
-
-	// Tech support fraud
-	if strings.Contains(allText, "virus") || strings.Contains(allText, "malware") ||
-		strings.Contains(allText, "hacked") || strings.Contains(allText, "remote access") ||
-		strings.Contains(allText, "technical support") {
-		return "tech_support_fraud"
-	}
-
-	// Lottery/prize/cashback fraud
-	if strings.Contains(allText, "prize") || strings.Contains(allText, "lottery") ||
-		strings.Contains(allText, "winner") || strings.Contains(allText, "cashback") ||
-		strings.Contains(allText, "reward") || strings.Contains(allText, "refund") {
-		return "lottery_fraud"
-	}
-
-	// Delivery/parcel/customs fraud
-	if strings.Contains(allText, "parcel") || strings.Contains(allText, "customs") ||
-		strings.Contains(allText, "package") || strings.Contains(allText, "courier") {
-		return "delivery_fraud"
-	}
-
-	// Check for bank fraud indicators
-	if strings.Contains(allText, "bank") || strings.Contains(allText, "account") ||
-		strings.Contains(allText, "blocked") || strings.Contains(allText, "suspended") ||
-		strings.Contains(allText, "otp") {
-		return "bank_fraud"
-	}
-
-	// Check for UPI fraud indicators
-	if strings.Contains(allText, "upi") || strings.Contains(allText, "payment") ||
-		strings.Contains(allText, "verify") || strings.Contains(allText, "kyc") {
-		return "upi_fraud"
-	}
-
-	// Check for phishing indicators
-	if len(session.Context.Intel.Link) > 0 || strings.Contains(allText, "click") ||
-		strings.Contains(allText, "link") {
-		return "phishing"
-	}
-
-	// Check for impersonation
-	if strings.Contains(allText, "customer care") || strings.Contains(allText, "support team") ||
-		strings.Contains(allText, "rbi") {
-		return "impersonation_fraud"
-	}
-
-	// Default to generic scam if detected
-	if session.Context.ScamDetected {
-		return "generic_scam"
-	}
-
-	return "unknown"
-}
-
 func determineConfidenceLevel(session *internal.SessionData) string {
 	if !session.Context.ScamDetected {
 		return "low"
 	}
 
 	// Calculate confidence based on intel count and red flags
-	intelCount := len(session.Context.Intel.UPI) + len(session.Context.Intel.Phone) +
-		len(session.Context.Intel.Link) + len(session.Context.Intel.Bank) + len(session.Context.Intel.Email) +
-		len(session.Context.Intel.CaseIDs) + len(session.Context.Intel.PolicyNumbers) +
-		len(session.Context.Intel.OrderNumbers) + len(session.Context.Intel.CardNumbers) + len(session.Context.Intel.IFSCCodes)
+	intelCount := session.Context.Intel.Count()
 	redFlagCount := len(session.Context.RedFlagsIdentified)
 
 	// High confidence: 3+ red flags or 2+ intel items
@@ -584,6 +803,11 @@ func determineConfidenceLevel(session *internal.SessionData) string {
 	return "low"
 }
 
+// roundConfidence keeps two decimals of a 0–1 confidence
+func roundConfidence(c float64) float64 {
+	return math.Round(c*100) / 100
+}
+
 func containsString(slice []string, str string) bool {
 	for _, item := range slice {
 		if item == str {
@@ -0,0 +1,295 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/muskiteer/Ai-Scam/internal"
+)
+
+// callbacks counts the final reports posted to CALLBACK_URL
+var callbacks atomic.Int32
+
+// sessions numbers the sessions of the tests, which share the global store
+var sessions atomic.Int32
+
+func newSessionID(t *testing.T) string {
+	return fmt.Sprintf("%s-%d", t.Name(), sessions.Add(1))
+}
+
+// llmReply is what the stub LLM server answers. A scammer message containing
+// llmHang makes it hang instead, past LLM_TIMEOUT.
+const (
+	llmReply = "Which branch are you calling from, sir?"
+	llmHang  = "[hang]"
+)
+
+// llmCalls counts the chat completions the stub LLM server received
+var llmCalls atomic.Int32
+
+// newLLMServer starts an OpenAI-compatible chat completions stub
+func newLLMServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		llmCalls.Add(1)
+		var req struct {
+			Messages []struct {
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if n := len(req.Messages); n > 0 && strings.Contains(req.Messages[n-1].Content, llmHang) {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+			return
+		}
+		fmt.Fprintf(w, `{"choices":[{"message":{"role":"assistant","content":%q}}]}`, llmReply)
+	}))
+}
+
+func TestMain(m *testing.M) {
+	callback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		callbacks.Add(1)
+	}))
+	llm := newLLMServer()
+	// The LLM chain against the stub, nothing written to disk and no waiting
+	// between turns
+	for key, value := range map[string]string{
+		"API_KEY":             "",
+		"CALLBACK_URL":        callback.URL,
+		"RESPONDER":           "chain",
+		"GROQ_API_KEY":        "test-key",
+		"GROQ_BASE_URL":       llm.URL,
+		"LLM_PROVIDERS":       "",
+		"LLM_TIMEOUT":         "300ms",
+		"STRATEGY_STATS_FILE": "off",
+		"HONEYTOKEN_FILE":     "off",
+		"CANARY_FILE":         "off",
+		"CANARY_BASE_URL":     "",
+	} {
+		os.Setenv(key, value)
+	}
+	turnDelay = 0
+	code := m.Run()
+	llm.Close()
+	callback.Close()
+	os.Exit(code)
+}
+
+// engage posts one scammer message to StartConvo
+func engage(t *testing.T, sessionID, text string, history ...MessageResponse) (int, Response) {
+	t.Helper()
+	body, _ := json.Marshal(Request{
+		SessionID:    sessionID,
+		Message:      MessageResponse{Sender: "scammer", Text: text},
+		ConvoHistory: history,
+		Metadata:     Metadata{Channel: "SMS", Language: "English", Locale: "IN"},
+	})
+	rec := httptest.NewRecorder()
+	StartConvo(rec, httptest.NewRequest(http.MethodPost, "/api/engage", bytes.NewReader(body)))
+	var resp Response
+	json.NewDecoder(rec.Body).Decode(&resp)
+	return rec.Code, resp
+}
+
+func TestStartConvoRepliesThroughTheLLM(t *testing.T) {
+	before := llmCalls.Load()
+	code, resp := engage(t, newSessionID(t), "Hello sir, I am calling from your bank about your account")
+	if code != http.StatusOK {
+		t.Fatalf("HTTP %d, %q", code, resp.Reply)
+	}
+	if resp.Reply != llmReply {
+		t.Errorf("reply %q, want the LLM's %q", resp.Reply, llmReply)
+	}
+	if llmCalls.Load() == before {
+		t.Error("the stub LLM server was not called")
+	}
+}
+
+func TestStartConvoFallsBackToTemplatesOnTimeout(t *testing.T) {
+	begin := time.Now()
+	code, resp := engage(t, newSessionID(t), "Hello sir, I am calling from your bank "+llmHang)
+	if code != http.StatusOK {
+		t.Fatalf("HTTP %d, %q", code, resp.Reply)
+	}
+	if resp.Reply == "" || resp.Reply == llmReply {
+		t.Errorf("reply %q, want a template reply", resp.Reply)
+	}
+	if elapsed := time.Since(begin); elapsed > 2*time.Second {
+		t.Errorf("turn took %v, LLM_TIMEOUT is 300ms", elapsed)
+	}
+}
+
+func TestStartConvoSerialisesConcurrentTurns(t *testing.T) {
+	sessionID := newSessionID(t)
+	const turns = 8
+	var wg sync.WaitGroup
+	codes := make([]int, turns)
+	replies := make([]string, turns)
+	for i := 0; i < turns; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			code, resp := engage(t, sessionID, message(i))
+			codes[i], replies[i] = code, resp.Reply
+		}(i)
+	}
+	wg.Wait()
+
+	for i := range codes {
+		if codes[i] != http.StatusOK || replies[i] == "" {
+			t.Errorf("request %d: HTTP %d, reply %q", i, codes[i], replies[i])
+		}
+	}
+	session := internal.GetStore().Get(sessionID)
+	session.BeginTurn()
+	defer session.EndTurn()
+	if session.Context.TurnCount != turns {
+		t.Errorf("TurnCount = %d, want %d", session.Context.TurnCount, turns)
+	}
+	if len(session.Transcript) != 2*turns {
+		t.Fatalf("transcript has %d turns, want %d", len(session.Transcript), 2*turns)
+	}
+	// Each scammer message is followed by its own reply
+	for i, turn := range session.Transcript {
+		want := internal.SenderScammer
+		if i%2 == 1 {
+			want = internal.SenderAgent
+		}
+		if turn.Sender != want {
+			t.Fatalf("transcript turn %d is from %s, want %s", i, turn.Sender, want)
+		}
+	}
+	// and no message was lost or recorded twice
+	seen := map[string]int{}
+	for _, msg := range session.MessageHistory {
+		seen[msg]++
+	}
+	for i := 0; i < turns; i++ {
+		if n := seen[message(i)]; n != 1 {
+			t.Errorf("message %d recorded %d times", i, n)
+		}
+	}
+}
+
+func TestStartConvoWaitsOutTheDelayWithoutTheSession(t *testing.T) {
+	turnDelay = 300 * time.Millisecond
+	t.Cleanup(func() { turnDelay = 0 })
+	sessionID := newSessionID(t)
+	const turns = 4
+	var wg sync.WaitGroup
+	begin := time.Now()
+	for i := 0; i < turns; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if code, _ := engage(t, sessionID, message(i)); code != http.StatusOK {
+				t.Errorf("request %d: HTTP %d", i, code)
+			}
+		}(i)
+	}
+	wg.Wait()
+	// Queued turns would take turns x turnDelay if each slept holding the session
+	if elapsed := time.Since(begin); elapsed >= 2*turnDelay {
+		t.Errorf("%d concurrent turns took %v with a %v delay", turns, elapsed, turnDelay)
+	}
+}
+
+func message(i int) string {
+	return fmt.Sprintf("Your SBI account %d will be blocked today, share the OTP or pay to fraud.desk%d@ybl", i, i)
+}
+
+func TestStartConvoRejectsFinishedSession(t *testing.T) {
+	sessionID := newSessionID(t)
+	before := callbacks.Load()
+	for turn := 1; turn <= 15; turn++ {
+		code, resp := engage(t, sessionID, "This is the SBI fraud department, your account is blocked, share the OTP now")
+		if code != http.StatusOK {
+			t.Fatalf("turn %d: HTTP %d, %q", turn, code, resp.Reply)
+		}
+	}
+	if !internal.GetStore().Finished(sessionID) {
+		t.Fatal("session not finished after 15 turns")
+	}
+
+	// Late and concurrent requests for the closed session are all refused
+	var wg sync.WaitGroup
+	var refused atomic.Int32
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if code, _ := engage(t, sessionID, "Hello? Are you there?"); code == http.StatusConflict {
+				refused.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if refused.Load() != 4 {
+		t.Errorf("%d of 4 requests after the final turn refused", refused.Load())
+	}
+
+	// The reports for turn 10 and turn 15 are sent in the background
+	for i := 0; i < 200 && callbacks.Load()-before < 2; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := callbacks.Load() - before; n != 2 {
+		t.Errorf("%d callbacks, want 2", n)
+	}
+}
+
+func TestStartConvoScoresOnlyScammerHistory(t *testing.T) {
+	const text = "Sir this is the bank calling about your account"
+	score := func(history ...MessageResponse) float64 {
+		sessionID := newSessionID(t)
+		if code, resp := engage(t, sessionID, text, history...); code != http.StatusOK {
+			t.Fatalf("HTTP %d, %q", code, resp.Reply)
+		}
+		session := internal.GetStore().Get(sessionID)
+		session.BeginTurn()
+		defer session.EndTurn()
+		return session.Context.Score.Score
+	}
+
+	alone := score()
+	// The platform sends our own replies as "user"
+	ours := score(MessageResponse{Sender: "user", Text: "Should I send the OTP to unblock my SBI bank account urgently? Tell me the UPI ID"})
+	if ours != alone {
+		t.Errorf("score with our own replies in the history = %.1f, without = %.1f", ours, alone)
+	}
+	theirs := score(MessageResponse{Sender: "scammer", Text: "Your SBI account will be blocked today, share the OTP immediately"})
+	if theirs <= alone {
+		t.Errorf("score with scammer history = %.1f, not above %.1f", theirs, alone)
+	}
+}
+
+func TestStartConvoValidatesRequests(t *testing.T) {
+	for name, body := range map[string]string{
+		"malformed":  `{"sessionId": `,
+		"no session": `{"message": {"sender": "scammer", "text": "hi"}}`,
+		"no text":    `{"sessionId": "s", "message": {"sender": "scammer", "text": ""}}`,
+	} {
+		rec := httptest.NewRecorder()
+		StartConvo(rec, httptest.NewRequest(http.MethodPost, "/api/engage", bytes.NewReader([]byte(body))))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: HTTP %d, want 400", name, rec.Code)
+		}
+	}
+	rec := httptest.NewRecorder()
+	StartConvo(rec, httptest.NewRequest(http.MethodGet, "/api/engage", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET: HTTP %d, want 405", rec.Code)
+	}
+}
@@ -38,6 +38,19 @@ var (
 	// NEW: Order number/ID patterns - expanded
 	OrderNumberRegex = regexp.MustCompile(`(?i)(?:order|ord|purchase|booking|reservation|shipment|tracking)[\s\.\-:#]*(?:no|number|num|id|#)?[\s\.\-:#]*([A-Z0-9]{6,25}|\d{8,16})`)
 
+	// Organisations scammers commonly claim to represent
+	ClaimedOrgRegex = regexp.MustCompile(`(?i)\b(state\s*bank\s*of\s*india|sbi|hdfc(?:\s*bank)?|icici(?:\s*bank)?|axis\s*bank|kotak(?:\s*mahindra)?(?:\s*bank)?|punjab\s*national\s*bank|pnb|bank\s*of\s*baroda|canara\s*bank|rbi|reserve\s*bank(?:\s*of\s*india)?|npci|paytm|phonepe|google\s*pay|amazon|flipkart|fedex|dhl|blue\s*dart|india\s*post|customs|cbi|income\s*tax(?:\s*department)?|cyber\s*(?:crime|cell)|trai|narcotics(?:\s*control\s*bureau)?|microsoft|apple)\b`)
+
+	// Badge or service numbers of claimed police, CBI or customs officers. An
+	// officer or employee needs an explicit "id" or "number" so that "pay the
+	// officer 2000" is not taken for one.
+	OfficerIDRegex = regexp.MustCompile(`(?i)\b(?:(?:officer|employee)[\s\-]*(?:id|no|number|num|code)|badge|buckle)(?:[\s\-]*(?:id|no|number|num|code))?[\s\.\-:#]*(?:is\s+)?([A-Z]{0,5}(?:[\-/][A-Z]{1,5})*[\-/]?\d{3,10})\b`)
+
+	// Skype handles: live:.cid.* style IDs, or a handle given as "skype id/name
+	// is ..." that has a digit, dot or underscore in it, so that "join the
+	// skype call immediately" yields nothing
+	SkypeIDRegex = regexp.MustCompile(`(?i)\bskype\s*(?:id|name|handle)\s*(?:is|:)\s*([a-z][a-z0-9\-]*[0-9._][a-z0-9._\-]*)|\b(live:\.?[a-z0-9][a-z0-9._\-]{3,31})`)
+
 	// NEW: Generic ID patterns (employee ID, reference ID, etc.)
 	ReferenceIDRegex = regexp.MustCompile(`(?i)(?:ref(?:erence)?|id|ticket|case|complaint)[\s\.\-:#]*([A-Z0-9]{6,20})`)
 )
@@ -73,6 +86,8 @@ func ExtractIntel(input string, confidence int) Intel {
 		OrderNumbers:  []string{},
 		CardNumbers:   []string{},
 		IFSCCodes:     []string{},
+		OfficerIDs:    []string{},
+		SkypeIDs:      []string{},
 	}
 
 	// Normalize input for better matching
@@ -272,9 +287,47 @@ func ExtractIntel(input string, confidence int) Intel {
 		}
 	}
 
+	// ============ EXTRACT OFFICER IDs ============
+	for _, match := range OfficerIDRegex.FindAllStringSubmatch(input, -1) {
+		officerID := strings.ToUpper(strings.TrimSpace(match[1]))
+		if !phoneSet[extractDigits(officerID)] && !containsString(intel.OfficerIDs, officerID) {
+			intel.OfficerIDs = append(intel.OfficerIDs, officerID)
+		}
+	}
+
+	// ============ EXTRACT SKYPE IDs ============
+	for _, match := range SkypeIDRegex.FindAllStringSubmatch(input, -1) {
+		skypeID := strings.ToLower(strings.TrimRight(match[1]+match[2], ".-"))
+		if len(skypeID) < 6 || len(skypeID) > 37 {
+			continue
+		}
+		if !isEmail(skypeID) && !containsString(intel.SkypeIDs, skypeID) {
+			intel.SkypeIDs = append(intel.SkypeIDs, skypeID)
+		}
+	}
+
 	return intel
 }
 
+// ExtractClaimedOrg returns the first organisation the text claims to be from, or ""
+func ExtractClaimedOrg(input string) string {
+	match := ClaimedOrgRegex.FindString(input)
+	if match == "" {
+		return ""
+	}
+	acronyms := map[string]bool{"sbi": true, "hdfc": true, "icici": true, "pnb": true, "rbi": true,
+		"npci": true, "dhl": true, "cbi": true, "trai": true}
+	words := strings.Fields(strings.ToLower(match))
+	for i, w := range words {
+		if acronyms[w] {
+			words[i] = strings.ToUpper(w)
+		} else {
+			words[i] = strings.ToUpper(w[:1]) + w[1:]
+		}
+	}
+	return strings.Join(words, " ")
+}
+
 // extractDigits returns only the digit characters from a string
 func extractDigits(s string) string {
 	var result strings.Builder
@@ -301,6 +354,9 @@ func MergeIntel(existing Intel, new Intel) Intel {
 		OrderNumbers:  limitItems(deduplicate(append(existing.OrderNumbers, new.OrderNumbers...)), maxIntelPerType),
 		CardNumbers:   limitItems(deduplicate(append(existing.CardNumbers, new.CardNumbers...)), maxIntelPerType),
 		IFSCCodes:     limitItems(deduplicate(append(existing.IFSCCodes, new.IFSCCodes...)), maxIntelPerType),
+		OfficerIDs:    limitItems(deduplicate(append(existing.OfficerIDs, new.OfficerIDs...)), maxIntelPerType),
+		SkypeIDs:      limitItems(deduplicate(append(existing.SkypeIDs, new.SkypeIDs...)), maxIntelPerType),
+		Links:         append(existing.Links, new.Links...),
 	}
 	return merged
 }
@@ -0,0 +1,29 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExtractOfficerAndSkypeIDs(t *testing.T) {
+	for _, tc := range []struct {
+		text           string
+		officer, skype string
+	}{
+		{"Officer ID: CBI-2231, note it down", "CBI-2231", ""},
+		{"My badge number is 4521", "4521", ""},
+		{"Join skype live:cbi.officer_22 now", "", "live:cbi.officer_22"},
+		{"My skype id is inspector.sharma01", "", "inspector.sharma01"},
+		{"Join the skype call immediately", "", ""},
+		{"Pay the service 2000 now", "", ""},
+		{"The officer will call you at 4 pm", "", ""},
+	} {
+		intel := ExtractIntel(tc.text, 50)
+		if got := strings.Join(intel.OfficerIDs, ","); got != tc.officer {
+			t.Errorf("%q: officer IDs %q, want %q", tc.text, got, tc.officer)
+		}
+		if got := strings.Join(intel.SkypeIDs, ","); got != tc.skype {
+			t.Errorf("%q: Skype IDs %q, want %q", tc.text, got, tc.skype)
+		}
+	}
+}
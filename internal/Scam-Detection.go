@@ -1,167 +1,182 @@
 package internal
 
 import (
-	"regexp"
+	"math"
+	"sort"
 )
 
+// ScamIndicators accumulates what the detection rules found in one message.
+// The patterns, weights, flags and combinations come from the rule file, see
+// RuleSet.
 type ScamIndicators struct {
 	Score            int
 	Words            []string
-	HasUrgency       bool
-	HasThreat        bool
-	HasFinancial     bool
-	HasCredential    bool
-	HasImpersonation bool
-	HasLottery       bool
-	HasTechSupport   bool
-	HasGovtThreat    bool
+	Hits             []RuleHit       // Rules that matched, in rule file order
+	Flags            map[string]bool // Indicator flags such as "urgency" or "credential"
+	RulesVersion     int             // Version of the rule file that scored the message
+	ModelVersion     int             // Classifier blended into Score, 0 when none is loaded
+	ModelProbability float64         // Classifier probability that the message is a scam
+	ModelPoints      int             // Points the classifier added to Score, negative when it took some away
+
+	rules *RuleSet
 }
 
-// EXPANDED: Added more urgency patterns
-var regexUrgent = regexp.MustCompile(`(?i)\b(urgent|immediately|right\s*now|today|within\s*\d+\s*(minutes?|hours?)|final\s*warning)\b`)
-var regexAccountThreatWords = regexp.MustCompile(`(?i)\b(account|upi|bank).*(blocked|suspended|disabled|closed|frozen)\b`)
-var regexVerificationWords = regexp.MustCompile(`(?i)\b(verify|verification|kyc|re-?activate|update\s*kyc)\b`)
-var regexPaymentWords = regexp.MustCompile(`(?i)\b(pay|payment|transfer|send|deposit)\b`)
-var regexOTPWords = regexp.MustCompile(`(?i)\b(otp|one\s*time\s*password|pin|cvv|password)\b`)
-var regexImpersonationWords = regexp.MustCompile(`(?i)\b(bank|sbi|hdfc|icici|axis|rbi|customer\s*care|support\s*team)\b`)
-
-// Action words
-var regexActionWords = regexp.MustCompile(`(?i)\b(click|tap|call|dial|visit|open\s*link|contact|reply|download)\b`)
-
-// Lottery and prize scam patterns
-var regexLotteryWords = regexp.MustCompile(`(?i)\b(prize|lottery|winner|won|congratulations|reward|cashback|refund|bonus|gift)\b`)
-
-// Tech support scam patterns
-var regexTechSupportWords = regexp.MustCompile(`(?i)\b(virus|malware|hacked|compromised|remote\s*access|technical\s*support|install\s*software)\b`)
+// RuleHit is a rule that matched a message and what it contributed
+type RuleHit struct {
+	Rule   string `json:"rule"`
+	Text   string `json:"text"`  // First match of the pattern
+	Start  int    `json:"start"` // Byte offsets of Text in the message
+	End    int    `json:"end"`
+	Weight int    `json:"weight"` // Added to the message score
+}
 
-// Government threat and legal intimidation patterns
-var regexGovtThreatWords = regexp.MustCompile(`(?i)\b(police|cbi|enforcement|income\s*tax|court|arrest|warrant|legal\s*action|government\s*official)\b`)
+// DetectionTrace explains a scam decision so analysts can audit it
+type DetectionTrace struct {
+	Turn             int       `json:"turn,omitempty"`
+	Message          string    `json:"message,omitempty"`
+	RulesVersion     int       `json:"rulesVersion"`
+	Score            int       `json:"score"` // Rule weights plus ModelPoints
+	Hits             []RuleHit `json:"hits,omitempty"`
+	ModelVersion     int       `json:"modelVersion,omitempty"`
+	ModelProbability float64   `json:"modelProbability,omitempty"`
+	ModelPoints      int       `json:"modelPoints,omitempty"`
+	Flags            []string  `json:"flags,omitempty"`
+	Scam             bool      `json:"scam"`
+	Combination      string    `json:"combination,omitempty"` // Combination that fired
+	// Threshold is the minimum score of the combination that fired. When none
+	// did, it is the lowest minimum score of a combination whose flags are
+	// all present, i.e. the score the message fell short of, or 0.
+	Threshold int `json:"threshold,omitempty"`
+}
 
-// Delivery and parcel scam patterns
-var regexDeliveryWords = regexp.MustCompile(`(?i)\b(parcel|package|customs|courier|shipment|detained|delivery\s*fee)\b`)
+// Has reports whether a rule set the indicator flag
+func (s *ScamIndicators) Has(flag string) bool {
+	return s.Flags[flag]
+}
 
-// Job and investment scam patterns
-var regexJobScamWords = regexp.MustCompile(`(?i)\b(job\s*offer|work\s*from\s*home|part[\s\-]time|earn\s*money|investment\s*return|profit\s*guarantee|easy\s*income)\b`)
+func (s *ScamIndicators) flagList() []string {
+	flags := make([]string, 0, len(s.Flags))
+	for f := range s.Flags {
+		flags = append(flags, f)
+	}
+	sort.Strings(flags)
+	return flags
+}
 
 func ScamDetection(input string, indicators *ScamIndicators) {
-
-	if matches := regexUrgent.FindStringSubmatch(input); len(matches) > 0 {
-		indicators.Score += 40
-		indicators.Words = append(indicators.Words, matches[0])
-		indicators.HasUrgency = true
-	}
-	if matches := regexAccountThreatWords.FindStringSubmatch(input); len(matches) > 0 {
-		indicators.Score += 40
-		indicators.Words = append(indicators.Words, matches[0])
-		indicators.HasThreat = true
-	}
-	if matches := regexVerificationWords.FindStringSubmatch(input); len(matches) > 0 {
-		indicators.Score += 20
-		indicators.Words = append(indicators.Words, matches[0])
-		indicators.HasFinancial = true
-	}
-	if matches := regexPaymentWords.FindStringSubmatch(input); len(matches) > 0 {
-		indicators.Score += 25
-		indicators.Words = append(indicators.Words, matches[0])
-		indicators.HasFinancial = true
-	}
-	if matches := regexOTPWords.FindStringSubmatch(input); len(matches) > 0 {
-		indicators.Score += 35
-		indicators.Words = append(indicators.Words, matches[0])
-		indicators.HasCredential = true
-	}
-	if matches := regexImpersonationWords.FindStringSubmatch(input); len(matches) > 0 {
-		indicators.Score += 15 // Increased from 10
-		indicators.Words = append(indicators.Words, matches[0])
-		indicators.HasImpersonation = true
-	}
-	// Action word detection
-	if matches := regexActionWords.FindStringSubmatch(input); len(matches) > 0 {
-		indicators.Score += 15
-		indicators.Words = append(indicators.Words, matches[0])
-	}
-	// Lottery/prize scam detection
-	if matches := regexLotteryWords.FindStringSubmatch(input); len(matches) > 0 {
-		indicators.Score += 30
-		indicators.Words = append(indicators.Words, matches[0])
-		indicators.HasLottery = true
-		indicators.HasFinancial = true
-	}
-	// Tech support scam detection
-	if matches := regexTechSupportWords.FindStringSubmatch(input); len(matches) > 0 {
-		indicators.Score += 25
-		indicators.Words = append(indicators.Words, matches[0])
-		indicators.HasTechSupport = true
-	}
-	// Government threat / legal intimidation detection
-	if matches := regexGovtThreatWords.FindStringSubmatch(input); len(matches) > 0 {
-		indicators.Score += 35
-		indicators.Words = append(indicators.Words, matches[0])
-		indicators.HasGovtThreat = true
-		indicators.HasThreat = true
-	}
-	// Delivery/parcel scam detection
-	if matches := regexDeliveryWords.FindStringSubmatch(input); len(matches) > 0 {
-		indicators.Score += 20
-		indicators.Words = append(indicators.Words, matches[0])
-		indicators.HasFinancial = true
-	}
-	// Job/investment scam detection
-	if matches := regexJobScamWords.FindStringSubmatch(input); len(matches) > 0 {
-		indicators.Score += 20
-		indicators.Words = append(indicators.Words, matches[0])
+	if rules := GetRules(); rules != nil {
+		rules.Detect(input, indicators)
+		rules.Blend(input, GetClassifier(), indicators)
 	}
 }
 
-// IsScam - OPTIMIZED: Lower thresholds to maximize scam detection
+// IsScam reports whether any combination of the rule file holds for the
+// indicators, using the rules that scored them
 func IsScam(indicators *ScamIndicators) bool {
-	hasUrgencyOrThreat := indicators.HasUrgency || indicators.HasThreat
-	hasFinancialOrCredential := indicators.HasFinancial || indicators.HasCredential
-
-	// CASE 1: Classic pattern (lowered threshold from 60 to 40)
-	if hasUrgencyOrThreat && hasFinancialOrCredential && indicators.Score >= 40 {
-		return true
-	}
-
-	// CASE 2: Impersonation + credential (bank asking for OTP) - lowered from 45 to 30
-	if indicators.HasImpersonation && indicators.HasCredential && indicators.Score >= 30 {
-		return true
-	}
-
-	// CASE 3: Impersonation + financial (fake bank asking for payment) - lowered from 50 to 35
-	if indicators.HasImpersonation && indicators.HasFinancial && indicators.Score >= 35 {
-		return true
-	}
+	rules := indicators.ruleSet()
+	return rules != nil && rules.Decide(indicators) != nil
+}
 
-	// CASE 4: Impersonation + urgency/threat - lowered from 55 to 40
-	if indicators.HasImpersonation && hasUrgencyOrThreat && indicators.Score >= 40 {
-		return true
-	}
+// Trace explains the decision IsScam takes on the indicators
+func Trace(indicators *ScamIndicators) DetectionTrace {
+	trace := DetectionTrace{
+		RulesVersion: indicators.RulesVersion,
+		Score:        indicators.Score,
+		Hits:         indicators.Hits,
+		Flags:        indicators.flagList(),
+
+		ModelVersion:     indicators.ModelVersion,
+		ModelProbability: math.Round(indicators.ModelProbability*1000) / 1000,
+		ModelPoints:      indicators.ModelPoints,
+	}
+	rules := indicators.ruleSet()
+	if rules == nil {
+		return trace
+	}
+	trace.RulesVersion = rules.Version
+	if c := rules.Decide(indicators); c != nil {
+		trace.Scam, trace.Combination, trace.Threshold = true, c.ID, c.MinScore
+		return trace
+	}
+	for _, c := range rules.Combinations {
+		flagsOnly := c
+		flagsOnly.MinScore = 0
+		if flagsOnly.holds(indicators) && (trace.Threshold == 0 || c.MinScore < trace.Threshold) {
+			trace.Threshold = c.MinScore
+		}
+	}
+	return trace
+}
 
-	// CASE 5: Any combination with decent score - lowered from 100 to 60
-	if indicators.Score >= 60 {
-		return true
+func (s *ScamIndicators) ruleSet() *RuleSet {
+	if s.rules != nil {
+		return s.rules
 	}
+	return GetRules()
+}
 
-	// CASE 6: Financial + credential even without urgency
-	if indicators.HasFinancial && indicators.HasCredential && indicators.Score >= 40 {
-		return true
-	}
+// SessionScore accumulates the evidence of every scammer message of a session,
+// so a scammer who spreads urgency, impersonation and a payment demand over
+// three messages is judged on all of them. The score decays before each
+// message and a rule firing again adds less each time, as configured by the
+// session section of the rule file.
+type SessionScore struct {
+	Score       float64
+	Confidence  float64         // Score mapped to 0–1, 0.5 at the rule file's halfScore
+	Detected    bool            // A combination holds for the session as a whole
+	Combination string          // The combination that holds
+	Messages    int             // Messages added
+	Hits        map[string]int  // Times each rule fired
+	Flags       map[string]bool // Indicator flags set by any message
+
+	rules *RuleSet
+}
 
-	// CASE 7: Lottery/prize + financial (classic cashback/refund scam)
-	if indicators.HasLottery && indicators.HasFinancial {
-		return true
+// Add folds the indicators of the next scammer message into the score
+func (s *SessionScore) Add(indicators *ScamIndicators) {
+	rules := indicators.ruleSet()
+	if rules == nil {
+		return
+	}
+	cfg := rules.Session
+
+	s.Score *= cfg.Decay
+	for _, hit := range indicators.Hits {
+		if s.Hits == nil {
+			s.Hits = map[string]int{}
+		}
+		s.Score += float64(hit.Weight) * math.Pow(cfg.RepeatWeight, float64(s.Hits[hit.Rule]))
+		s.Hits[hit.Rule]++
+	}
+	s.Score = math.Max(0, s.Score+float64(indicators.ModelPoints))
+	for f := range indicators.Flags {
+		if s.Flags == nil {
+			s.Flags = map[string]bool{}
+		}
+		s.Flags[f] = true
+	}
+	s.Messages++
+
+	s.Confidence = s.Score / (s.Score + cfg.HalfScore)
+	s.rules = rules
+	ind := s.indicators()
+	s.Detected, s.Combination = false, ""
+	if c := rules.Decide(&ind); c != nil {
+		s.Detected, s.Combination = true, c.ID
 	}
+}
 
-	// CASE 8: Government threat with a significant score
-	if indicators.HasGovtThreat && indicators.Score >= 35 {
-		return true
-	}
+// Trace explains the decision on the session as a whole
+func (s *SessionScore) Trace() DetectionTrace {
+	ind := s.indicators()
+	return Trace(&ind)
+}
 
-	// CASE 9: Tech support scam with urgency or high score
-	if indicators.HasTechSupport && (indicators.HasUrgency || indicators.Score >= 40) {
-		return true
+// indicators presents the session's evidence as those of a single message
+func (s *SessionScore) indicators() ScamIndicators {
+	ind := ScamIndicators{Score: int(math.Round(s.Score)), Flags: s.Flags, rules: s.rules}
+	if s.rules != nil {
+		ind.RulesVersion = s.rules.Version
 	}
-
-	return false
+	return ind
 }
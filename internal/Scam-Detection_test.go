@@ -0,0 +1,75 @@
+package internal
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+// sessionRules scores sessions with a single combination: urgency at 40 points
+var sessionRules = &RuleSet{
+	Version:      1,
+	Combinations: []Combination{{ID: "urgent", All: [][]string{{"urgency"}}, MinScore: 40}},
+	Session:      SessionScoring{Decay: 0.5, RepeatWeight: 0.5, HalfScore: 50},
+}
+
+// hits returns the indicators of a message that fired the rules, each weighing 20
+func hits(rules ...string) *ScamIndicators {
+	ind := &ScamIndicators{Flags: map[string]bool{}, rules: sessionRules}
+	for _, r := range rules {
+		ind.Hits = append(ind.Hits, RuleHit{Rule: r, Weight: 20})
+		ind.Score += 20
+		ind.Flags[r] = true
+	}
+	return ind
+}
+
+func TestSessionScoreAdd(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		messages []*ScamIndicators
+		score    float64
+		detected bool
+	}{
+		{"one message", []*ScamIndicators{hits("urgency")}, 20, false},
+		{"decay before each message", []*ScamIndicators{hits("urgency"), hits(), hits()}, 5, false},
+		{"different rules add up", []*ScamIndicators{hits("urgency"), hits("payment")}, 10 + 20, false},
+		{"repeats are damped", []*ScamIndicators{hits("urgency"), hits("urgency"), hits("urgency")}, (20*0.5+10)*0.5 + 5, false},
+		{"evidence spread over messages", []*ScamIndicators{hits("urgency"), hits("authority", "payment")}, 10 + 40, true},
+	} {
+		var s SessionScore
+		for _, ind := range tc.messages {
+			s.Add(ind)
+		}
+		if math.Abs(s.Score-tc.score) > 1e-9 || s.Detected != tc.detected {
+			t.Errorf("%s: score %.2f detected %v, want %.2f %v", tc.name, s.Score, s.Detected, tc.score, tc.detected)
+		}
+		if s.Messages != len(tc.messages) {
+			t.Errorf("%s: %d messages, want %d", tc.name, s.Messages, len(tc.messages))
+		}
+		if want := tc.score / (tc.score + 50); math.Abs(s.Confidence-want) > 1e-9 {
+			t.Errorf("%s: confidence %.3f, want %.3f", tc.name, s.Confidence, want)
+		}
+	}
+}
+
+func TestSessionScoreConfidenceBounds(t *testing.T) {
+	var s SessionScore
+	s.Add(hits())
+	if s.Confidence != 0 {
+		t.Errorf("confidence without evidence = %v, want 0", s.Confidence)
+	}
+	// Fresh rules every message, since a rule firing again adds less and less
+	for i := 0; i < 50; i++ {
+		s.Add(hits("urgency", fmt.Sprint("a", i), fmt.Sprint("b", i), fmt.Sprint("c", i)))
+		if s.Confidence < 0 || s.Confidence >= 1 {
+			t.Fatalf("message %d: confidence %v outside [0, 1)", i+1, s.Confidence)
+		}
+	}
+	if s.Confidence < 0.5 {
+		t.Errorf("confidence after 50 strong messages = %.2f, want above 0.5", s.Confidence)
+	}
+	if s.Combination != "urgent" {
+		t.Errorf("combination = %q, want urgent", s.Combination)
+	}
+}
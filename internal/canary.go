@@ -0,0 +1,465 @@
+package internal
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io/fs"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Canary links are URLs on this server that the victim sends as "the
+// screenshot of my payment". Each one belongs to a single session and serves
+// a decoy page, image or PDF. Whoever opens it is logged with IP, User-Agent,
+// headers and time; scammers tend to open them on their operations machines,
+// which ties the conversation to infrastructure text alone never reveals.
+
+// Canary kinds, each served as a different decoy
+const (
+	CanaryPage  = "page"  // Payment status page
+	CanaryImage = "image" // Payment screenshot
+	CanaryPDF   = "pdf"   // Transaction receipt
+)
+
+// Canary is a link handed to a session
+type Canary struct {
+	Token     string    `json:"token"`
+	Kind      string    `json:"kind"`
+	URL       string    `json:"url"`
+	SessionID string    `json:"sessionId"`
+	Turn      int       `json:"turn"`
+	Created   time.Time `json:"created"`
+}
+
+// CanaryHit is one request for a canary link
+type CanaryHit struct {
+	Token     string            `json:"token"`
+	Kind      string            `json:"kind"`
+	SessionID string            `json:"sessionId"`
+	IP        string            `json:"ip"`
+	UserAgent string            `json:"userAgent"`
+	Headers   map[string]string `json:"headers"`
+	Time      time.Time         `json:"time"`
+	Preview   bool              `json:"preview"` // A chat app fetching a link preview, not a person
+}
+
+// CanaryReport lists canaries and their hits, for one session or all
+type CanaryReport struct {
+	File     string      `json:"file,omitempty"`
+	BaseURL  string      `json:"baseUrl,omitempty"`
+	Updated  time.Time   `json:"updated"`
+	Canaries []Canary    `json:"canaries"`
+	Hits     []CanaryHit `json:"hits"`
+}
+
+// canaryLabels name each kind in prompts
+var canaryLabels = map[string]string{
+	CanaryPage:  "payment status page",
+	CanaryImage: "payment screenshot",
+	CanaryPDF:   "payment receipt",
+}
+
+// canaryExtensions make the URL look like the file it claims to be
+var canaryExtensions = map[string]string{
+	CanaryPage:  "",
+	CanaryImage: ".png",
+	CanaryPDF:   ".pdf",
+}
+
+// maxCanaryHits bounds the hits kept per canary, so a crawler cannot grow
+// the registry without limit
+const maxCanaryHits = 50
+
+// Each recorded hit rewrites the registry file, so a client gets at most
+// canaryHitsPerIP recorded hits per canaryHitWindow; further requests are
+// still served the decoy but not logged
+const (
+	canaryHitsPerIP = 5
+	canaryHitWindow = time.Minute
+)
+
+var (
+	regexCanaryPDF  = regexp.MustCompile(`(?i)\b(receipt|pdf|statement|slip|document)\b`)
+	regexCanaryPage = regexp.MustCompile(`(?i)\b(link|status|page|website)\b`)
+
+	// User agents of chat apps and crawlers that fetch link previews
+	regexPreviewAgent = regexp.MustCompile(`(?i)whatsapp|telegrambot|facebookexternalhit|twitterbot|slackbot|discordbot|skypeuripreview|linkedinbot|googlebot|bingbot`)
+)
+
+// CanaryKindFor picks the decoy that answers the message: a receipt when the
+// scammer asks for one, a status page for a link, otherwise a screenshot
+func CanaryKindFor(message string) string {
+	switch {
+	case regexCanaryPDF.MatchString(message):
+		return CanaryPDF
+	case regexCanaryPage.MatchString(message):
+		return CanaryPage
+	default:
+		return CanaryImage
+	}
+}
+
+// CanaryRegistry records the canaries handed out and who opened them. It is
+// shared by all sessions.
+type CanaryRegistry struct {
+	mu       sync.RWMutex
+	canaries map[string]*Canary
+	hits     []CanaryHit
+	updated  time.Time
+	perIP    map[string]*hitWindow // Hits recorded per client in the current window
+
+	saveMu  sync.Mutex
+	path    string // "" keeps the registry in memory only
+	baseURL string // Public URL of this server, "" disables canaries
+}
+
+// hitWindow counts the hits recorded for one client since start
+type hitWindow struct {
+	start time.Time
+	n     int
+}
+
+var (
+	canaries     *CanaryRegistry
+	canariesOnce sync.Once
+	canariesErr  error
+)
+
+// LoadCanaries reads the registry from CANARY_FILE (default canaries.json,
+// "off" to keep it in memory). Links are built on CANARY_BASE_URL, the
+// server's public address; without it no canaries are handed out and the
+// victim only promises to send the screenshot.
+func LoadCanaries() error {
+	canariesOnce.Do(func() {
+		path := os.Getenv("CANARY_FILE")
+		switch path {
+		case "":
+			path = "canaries.json"
+		case "off":
+			path = ""
+		}
+		canaries = &CanaryRegistry{
+			canaries: map[string]*Canary{},
+			path:     path,
+			baseURL:  strings.TrimRight(os.Getenv("CANARY_BASE_URL"), "/"),
+		}
+		canariesErr = canaries.load()
+		if canariesErr == nil {
+			log.Printf("Loaded canary registry: %d links, %d hits (base URL %q)", len(canaries.canaries), len(canaries.hits), canaries.baseURL)
+		}
+	})
+	return canariesErr
+}
+
+// GetCanaries returns the shared registry, loading it on first use
+func GetCanaries() *CanaryRegistry {
+	if err := LoadCanaries(); err != nil {
+		log.Printf("Canary registry failed to load, starting empty: %v", err)
+	}
+	return canaries
+}
+
+func (c *CanaryRegistry) load() error {
+	if c.path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(c.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var report CanaryReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return err
+	}
+	for _, canary := range report.Canaries {
+		if _, ok := canaryLabels[canary.Kind]; !ok || canary.Token == "" {
+			return fmt.Errorf("canary %q has unknown kind %q", canary.Token, canary.Kind)
+		}
+		cp := canary
+		c.canaries[cp.Token] = &cp
+	}
+	c.hits = report.Hits
+	c.updated = report.Updated
+	return nil
+}
+
+// Mint creates a canary of the kind without recording it; Issue records it
+// once the reply carries the link. It fails when CANARY_BASE_URL is not set.
+func (c *CanaryRegistry) Mint(kind string) (Canary, bool) {
+	if c.baseURL == "" {
+		return Canary{}, false
+	}
+	b := make([]byte, 9)
+	if _, err := rand.Read(b); err != nil {
+		log.Printf("Minting canary failed: %v", err)
+		return Canary{}, false
+	}
+	token := hex.EncodeToString(b)
+	return Canary{Token: token, Kind: kind, URL: c.baseURL + "/c/" + token + canaryExtensions[kind]}, true
+}
+
+// Issue records the canary as handed to the session if the reply contains its link
+func (c *CanaryRegistry) Issue(sessionID string, turn int, canary *Canary, reply string) bool {
+	if canary == nil || !strings.Contains(reply, canary.URL) {
+		return false
+	}
+	issued := *canary
+	issued.SessionID, issued.Turn, issued.Created = sessionID, turn, time.Now()
+
+	c.mu.Lock()
+	c.canaries[issued.Token] = &issued
+	c.updated = time.Now()
+	c.mu.Unlock()
+	c.persist()
+	return true
+}
+
+// Lookup finds the canary of a token taken from a URL path, with or without
+// its file extension
+func (c *CanaryRegistry) Lookup(token string) (Canary, bool) {
+	if dot := strings.IndexByte(token, '.'); dot >= 0 {
+		token = token[:dot]
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if canary, ok := c.canaries[token]; ok {
+		return *canary, true
+	}
+	return Canary{}, false
+}
+
+// RecordHit logs a request for the canary, unless the canary or the client
+// has already used up its share of hits
+func (c *CanaryRegistry) RecordHit(canary Canary, ip, userAgent string, headers map[string]string) CanaryHit {
+	hit := CanaryHit{
+		Token:     canary.Token,
+		Kind:      canary.Kind,
+		SessionID: canary.SessionID,
+		IP:        ip,
+		UserAgent: userAgent,
+		Headers:   headers,
+		Time:      time.Now(),
+		Preview:   regexPreviewAgent.MatchString(userAgent),
+	}
+
+	c.mu.Lock()
+	n := 0
+	for _, h := range c.hits {
+		if h.Token == canary.Token {
+			n++
+		}
+	}
+	if n >= maxCanaryHits || !c.allowHit(ip, hit.Time) {
+		c.mu.Unlock()
+		return hit
+	}
+	c.hits = append(c.hits, hit)
+	c.updated = time.Now()
+	c.mu.Unlock()
+	c.persist()
+	return hit
+}
+
+// allowHit counts a hit from the client, reporting whether it is within the
+// per-client limit. Windows that have ended are dropped. c.mu must be held.
+func (c *CanaryRegistry) allowHit(ip string, now time.Time) bool {
+	if c.perIP == nil {
+		c.perIP = map[string]*hitWindow{}
+	}
+	for client, w := range c.perIP {
+		if now.Sub(w.start) >= canaryHitWindow {
+			delete(c.perIP, client)
+		}
+	}
+	w, ok := c.perIP[ip]
+	if !ok {
+		w = &hitWindow{start: now}
+		c.perIP[ip] = w
+	}
+	if w.n >= canaryHitsPerIP {
+		return false
+	}
+	w.n++
+	return true
+}
+
+// Filter removes our own canary links from captured intel, since a scammer
+// pasting one back is not sharing a phishing link
+func (c *CanaryRegistry) Filter(intel Intel) Intel {
+	if c.baseURL == "" {
+		return intel
+	}
+	kept := intel.Link[:0:0]
+	for _, link := range intel.Link {
+		if !strings.HasPrefix(strings.ToLower(link), strings.ToLower(c.baseURL)+"/c/") {
+			kept = append(kept, link)
+		}
+	}
+	intel.Link = kept
+	return intel
+}
+
+// ForSession returns the canaries handed to the session and their hits
+func (c *CanaryRegistry) ForSession(sessionID string) CanaryReport {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	report := CanaryReport{Updated: c.updated}
+	for _, canary := range c.canaries {
+		if canary.SessionID == sessionID {
+			report.Canaries = append(report.Canaries, *canary)
+		}
+	}
+	sortCanaries(report.Canaries)
+	for _, hit := range c.hits {
+		if hit.SessionID == sessionID {
+			report.Hits = append(report.Hits, hit)
+		}
+	}
+	return report
+}
+
+// Report returns the whole registry
+func (c *CanaryRegistry) Report() CanaryReport {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	report := CanaryReport{File: c.path, BaseURL: c.baseURL, Updated: c.updated, Canaries: []Canary{}}
+	for _, canary := range c.canaries {
+		report.Canaries = append(report.Canaries, *canary)
+	}
+	sortCanaries(report.Canaries)
+	report.Hits = append([]CanaryHit{}, c.hits...)
+	return report
+}
+
+func sortCanaries(list []Canary) {
+	sort.Slice(list, func(i, j int) bool { return list[i].Created.Before(list[j].Created) })
+}
+
+// persist saves the registry, logging failures; a click must always get its decoy
+func (c *CanaryRegistry) persist() {
+	if c.path == "" {
+		return
+	}
+	c.saveMu.Lock()
+	defer c.saveMu.Unlock()
+
+	data, err := json.MarshalIndent(c.Report(), "", "  ")
+	if err == nil {
+		err = writeFileAtomic(c.path, data)
+	}
+	if err != nil {
+		log.Printf("Saving canary registry failed: %v", err)
+	}
+}
+
+// CanaryDecoy returns the content served for the canary and its content type.
+// The reference number is derived from the token, so every decoy differs but
+// stays the same on each visit.
+func CanaryDecoy(canary Canary) ([]byte, string) {
+	h := fnv.New64a()
+	h.Write([]byte(canary.Token))
+	ref := fmt.Sprintf("%012d", h.Sum64()%1e12)
+
+	switch canary.Kind {
+	case CanaryImage:
+		return decoyScreenshot(), "image/png"
+	case CanaryPDF:
+		return decoyReceipt(ref), "application/pdf"
+	default:
+		return []byte(fmt.Sprintf(decoyPage, ref, canary.Created.Format("02 Jan 2006, 03:04 PM"))), "text/html; charset=utf-8"
+	}
+}
+
+const decoyPage = `<!DOCTYPE html>
+<html lang="en">
+<head><meta charset="utf-8"><meta name="viewport" content="width=device-width, initial-scale=1"><title>Transaction Status</title></head>
+<body style="font-family: sans-serif; max-width: 420px; margin: 40px auto; color: #222">
+<h2 style="color: #5f259f">UPI Transaction Status</h2>
+<p><b>Status:</b> Pending at beneficiary bank</p>
+<p><b>UTR:</b> %s</p>
+<p><b>Date:</b> %s</p>
+<p>The amount will be credited or refunded within 48 hours. Please do not retry the payment.</p>
+</body>
+</html>
+`
+
+var (
+	screenshotOnce sync.Once
+	screenshotPNG  []byte
+)
+
+// decoyScreenshot draws a phone-sized image in the colours of a payment app
+// with blurred out text lines
+func decoyScreenshot() []byte {
+	screenshotOnce.Do(func() {
+		img := image.NewRGBA(image.Rect(0, 0, 360, 640))
+		fill := func(x0, y0, x1, y1 int, c color.RGBA) {
+			draw.Draw(img, image.Rect(x0, y0, x1, y1), &image.Uniform{c}, image.Point{}, draw.Src)
+		}
+		fill(0, 0, 360, 640, color.RGBA{245, 245, 245, 255})
+		fill(0, 0, 360, 72, color.RGBA{95, 37, 159, 255})
+		fill(150, 120, 210, 180, color.RGBA{255, 167, 38, 255})
+		for i, w := range []int{220, 160, 260, 180, 240, 120} {
+			y := 220 + i*44
+			fill((360-w)/2, y, (360+w)/2, y+14, color.RGBA{200, 200, 200, 255})
+		}
+		fill(40, 560, 320, 604, color.RGBA{95, 37, 159, 255})
+
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			log.Printf("Encoding decoy screenshot failed: %v", err)
+		}
+		screenshotPNG = buf.Bytes()
+	})
+	return screenshotPNG
+}
+
+// decoyReceipt writes a one page PDF receipt
+func decoyReceipt(ref string) []byte {
+	lines := []string{
+		"BT /F1 18 Tf 50 520 Td (UPI Transaction Receipt) Tj ET",
+		"BT /F1 12 Tf 50 480 Td (Status: Pending at beneficiary bank) Tj ET",
+		"BT /F1 12 Tf 50 456 Td (UTR: " + ref + ") Tj ET",
+		"BT /F1 12 Tf 50 432 Td (Amount will be credited or refunded within 48 hours.) Tj ET",
+	}
+	stream := strings.Join(lines, "\n")
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 420 595] /Contents 4 0 R /Resources << /Font << /F1 5 0 R >> >> >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(stream), stream),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return buf.Bytes()
+}
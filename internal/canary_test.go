@@ -0,0 +1,37 @@
+package internal
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAllowHitLimitsEachClient(t *testing.T) {
+	c := &CanaryRegistry{}
+	now := time.Now()
+	for i := 0; i < canaryHitsPerIP; i++ {
+		if !c.allowHit("203.0.113.7", now) {
+			t.Fatalf("hit %d refused within the limit", i+1)
+		}
+	}
+	if c.allowHit("203.0.113.7", now.Add(time.Second)) {
+		t.Error("hit over the limit allowed")
+	}
+	if !c.allowHit("198.51.100.2", now.Add(time.Second)) {
+		t.Error("another client was refused")
+	}
+	if !c.allowHit("203.0.113.7", now.Add(canaryHitWindow)) {
+		t.Error("hit refused after the window ended")
+	}
+}
+
+func TestRecordHitStopsAtTheClientLimit(t *testing.T) {
+	c := &CanaryRegistry{canaries: map[string]*Canary{}}
+	canary := Canary{Token: "tok", Kind: CanaryPage, SessionID: "s"}
+	for i := 0; i < canaryHitsPerIP+3; i++ {
+		c.RecordHit(canary, "203.0.113.7", "curl/8.0", nil)
+	}
+	c.RecordHit(canary, "198.51.100.2", "WhatsApp/2.23", nil)
+	if got := len(c.ForSession("s").Hits); got != canaryHitsPerIP+1 {
+		t.Errorf("%d hits recorded, want %d", got, canaryHitsPerIP+1)
+	}
+}
@@ -0,0 +1,262 @@
+package internal
+
+import (
+	"bufio"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// A naive Bayes classifier trained offline on labelled messages (see
+// cmd/train-classifier) complements the regex rules: it catches paraphrased
+// scams no pattern matches and pulls down messages where "send", "today" or
+// "pin" are innocent. The model is embedded from classifier/model.json, or
+// read from CLASSIFIER_MODEL ("off" disables it); how much it counts is set in
+// the classifier section of the rule file.
+//
+//go:embed classifier/model.json
+var embeddedModel []byte
+
+// LabeledMessage is one line of a JSONL training or evaluation corpus: a
+// single message, or the scammer messages of a whole conversation
+type LabeledMessage struct {
+	Text     string   `json:"text,omitempty"`
+	Messages []string `json:"messages,omitempty"` // A conversation instead of Text
+	Scam     bool     `json:"scam"`
+	ScamType string   `json:"scamType,omitempty"` // As reported by DetermineScamType
+}
+
+// Turns returns the messages of a conversation, or the single message
+func (m LabeledMessage) Turns() []string {
+	if len(m.Messages) > 0 {
+		return m.Messages
+	}
+	return []string{m.Text}
+}
+
+// ReadCorpus reads a JSONL corpus of labelled messages, skipping blank lines
+func ReadCorpus(path string) ([]LabeledMessage, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var messages []LabeledMessage
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var msg LabeledMessage
+		if err := json.Unmarshal([]byte(text), &msg); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		if strings.TrimSpace(strings.Join(msg.Turns(), "")) == "" {
+			return nil, fmt.Errorf("%s:%d: no text", path, line)
+		}
+		if msg.ScamType != "" && !msg.Scam {
+			return nil, fmt.Errorf("%s:%d: scamType on a message that is not a scam", path, line)
+		}
+		messages = append(messages, msg)
+	}
+	return messages, scanner.Err()
+}
+
+// Classes of the model
+const (
+	classHam  = 0
+	classScam = 1
+)
+
+// ClassifierModel is a binarized multinomial naive Bayes model: a token counts
+// once per message, however often it is repeated
+type ClassifierModel struct {
+	Version  int               `json:"version"`
+	MinCount int               `json:"minCount"` // Rarer tokens were dropped
+	Docs     [2]int            `json:"docs"`     // Messages per class, ham then scam
+	Tokens   [2]int            `json:"tokens"`   // Token occurrences per class
+	Counts   map[string][2]int `json:"counts"`   // Messages per class containing the token
+}
+
+var (
+	classifierMu      sync.RWMutex
+	currentClassifier *ClassifierModel
+	classifierOnce    sync.Once
+	classifierErr     error
+)
+
+// Tokenize lowercases the text and splits it into words and word bigrams.
+// Numbers become a placeholder by length, so an OTP, a phone number and an
+// amount are told apart without learning every value, and handles such as UPI
+// IDs become "<handle>".
+func Tokenize(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.IsMark(r) && r != '@'
+	})
+	words := make([]string, 0, len(fields))
+	for _, f := range fields {
+		switch {
+		case strings.Contains(f, "@"):
+			f = "<handle>"
+		case strings.IndexFunc(f, func(r rune) bool { return !unicode.IsDigit(r) }) < 0:
+			if len(f) > 12 {
+				f = "<num>"
+			} else {
+				f = "<num" + strconv.Itoa(len(f)) + ">"
+			}
+		case len([]rune(f)) < 2:
+			continue
+		}
+		words = append(words, f)
+	}
+	tokens := append([]string(nil), words...)
+	for i := 0; i+1 < len(words); i++ {
+		tokens = append(tokens, words[i]+" "+words[i+1])
+	}
+	return tokens
+}
+
+// TrainClassifier counts the tokens of the labelled messages and drops those
+// seen in fewer than minCount messages
+func TrainClassifier(messages []LabeledMessage, minCount, version int) (*ClassifierModel, error) {
+	m := &ClassifierModel{Version: version, MinCount: minCount, Counts: map[string][2]int{}}
+	for _, msg := range messages {
+		class := classHam
+		if msg.Scam {
+			class = classScam
+		}
+		m.Docs[class]++
+		for tok := range tokenSet(strings.Join(msg.Turns(), "\n")) {
+			c := m.Counts[tok]
+			c[class]++
+			m.Counts[tok] = c
+		}
+	}
+	for tok, c := range m.Counts {
+		if c[classHam]+c[classScam] < minCount {
+			delete(m.Counts, tok)
+			continue
+		}
+		m.Tokens[classHam] += c[classHam]
+		m.Tokens[classScam] += c[classScam]
+	}
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func tokenSet(text string) map[string]bool {
+	set := map[string]bool{}
+	for _, tok := range Tokenize(text) {
+		set[tok] = true
+	}
+	return set
+}
+
+func (m *ClassifierModel) validate() error {
+	var errs []error
+	if m.Version <= 0 {
+		errs = append(errs, errors.New("version must be positive"))
+	}
+	if m.Docs[classHam] == 0 || m.Docs[classScam] == 0 {
+		errs = append(errs, errors.New("needs both scam and ham messages"))
+	}
+	if len(m.Counts) == 0 {
+		errs = append(errs, errors.New("no tokens"))
+	}
+	return errors.Join(errs...)
+}
+
+// Probability returns the probability that the text is a scam. Tokens the
+// model has not seen are ignored, so a message without any known token gets
+// the share of scams in the training corpus.
+func (m *ClassifierModel) Probability(text string) float64 {
+	vocab := float64(len(m.Counts))
+	total := float64(m.Docs[classHam] + m.Docs[classScam])
+	logHam := math.Log(float64(m.Docs[classHam]) / total)
+	logScam := math.Log(float64(m.Docs[classScam]) / total)
+	for tok := range tokenSet(text) {
+		c, ok := m.Counts[tok]
+		if !ok {
+			continue
+		}
+		logHam += math.Log((float64(c[classHam]) + 1) / (float64(m.Tokens[classHam]) + vocab))
+		logScam += math.Log((float64(c[classScam]) + 1) / (float64(m.Tokens[classScam]) + vocab))
+	}
+	return 1 / (1 + math.Exp(logHam-logScam))
+}
+
+// LoadClassifier loads the classifier model and registers it for reloading.
+// It is called at startup so that a broken model stops the server.
+func LoadClassifier() error {
+	classifierOnce.Do(func() {
+		classifierErr = ReloadClassifier()
+		RegisterReloader("classifier", ReloadClassifier)
+	})
+	return classifierErr
+}
+
+// GetClassifier returns the current model, nil when it is disabled
+func GetClassifier() *ClassifierModel {
+	if err := LoadClassifier(); err != nil {
+		log.Printf("Classifier failed to load: %v", err)
+	}
+	classifierMu.RLock()
+	defer classifierMu.RUnlock()
+	return currentClassifier
+}
+
+// ReloadClassifier reads the embedded model or CLASSIFIER_MODEL
+func ReloadClassifier() error {
+	data, source := embeddedModel, "embedded model"
+	switch path := os.Getenv("CLASSIFIER_MODEL"); path {
+	case "":
+	case "off":
+		classifierMu.Lock()
+		currentClassifier = nil
+		classifierMu.Unlock()
+		log.Println("Classifier disabled")
+		return nil
+	default:
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return err
+		}
+		source = path
+	}
+	model, err := ParseClassifier(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", source, err)
+	}
+
+	classifierMu.Lock()
+	currentClassifier = model
+	classifierMu.Unlock()
+	log.Printf("Loaded classifier v%d: %d tokens from %d scam and %d ham messages",
+		model.Version, len(model.Counts), model.Docs[classScam], model.Docs[classHam])
+	return nil
+}
+
+// ParseClassifier parses and validates a model written by cmd/train-classifier
+func ParseClassifier(data []byte) (*ClassifierModel, error) {
+	var m ClassifierModel
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
@@ -0,0 +1,131 @@
+package internal
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var tinyCorpus = []LabeledMessage{
+	{Text: "Your account is blocked, share the OTP now", Scam: true},
+	{Text: "Share OTP now or account blocked", Scam: true},
+	{Messages: []string{"Sir this is SBI", "Send the OTP to unblock your account"}, Scam: true},
+	{Text: "See you at dinner tonight", Scam: false},
+	{Text: "Dinner is ready, come home now", Scam: false},
+}
+
+func TestTokenize(t *testing.T) {
+	got := strings.Join(Tokenize("Pay Rs 4500 to Ramesh@YBL, call 9876543210 a"), "|")
+	want := "pay|rs|<num4>|to|<handle>|call|<num10>|pay rs|rs <num4>|<num4> to|to <handle>|<handle> call|call <num10>"
+	if got != want {
+		t.Errorf("Tokenize = %s\nwant       %s", got, want)
+	}
+}
+
+func TestTrainClassifier(t *testing.T) {
+	m, err := TrainClassifier(tinyCorpus, 1, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Docs != [2]int{2, 3} || m.Version != 3 {
+		t.Errorf("docs %v version %d, want [2 3] and 3", m.Docs, m.Version)
+	}
+	// A token counts once per message, the conversation is one message
+	if c := m.Counts["otp"]; c != [2]int{0, 3} {
+		t.Errorf("otp counts %v, want [0 3]", c)
+	}
+	if c := m.Counts["now"]; c != [2]int{1, 2} {
+		t.Errorf("now counts %v, want [1 2]", c)
+	}
+
+	if p := m.Probability("please share the OTP, account blocked"); p < 0.9 {
+		t.Errorf("scam probability %.3f, want above 0.9", p)
+	}
+	if p := m.Probability("dinner tonight?"); p > 0.2 {
+		t.Errorf("ham probability %.3f, want below 0.2", p)
+	}
+	if p := m.Probability("zebra quantum"); math.Abs(p-0.6) > 1e-9 {
+		t.Errorf("probability without known tokens %.3f, want the scam share 0.6", p)
+	}
+
+	pruned, err := TrainClassifier(tinyCorpus, 2, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := pruned.Counts["sbi"]; ok || len(pruned.Counts) >= len(m.Counts) {
+		t.Errorf("minCount 2 kept %d of %d tokens, including sbi %v", len(pruned.Counts), len(m.Counts), ok)
+	}
+	if _, err := TrainClassifier(tinyCorpus[:3], 1, 1); err == nil {
+		t.Error("trained without ham messages")
+	}
+}
+
+func TestClassifierRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "corpus.jsonl")
+	var lines []string
+	for _, msg := range tinyCorpus {
+		line, _ := json.Marshal(msg)
+		lines = append(lines, string(line))
+	}
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n\n")+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	corpus, err := ReadCorpus(path)
+	if err != nil || len(corpus) != len(tinyCorpus) {
+		t.Fatalf("ReadCorpus: %d messages, %v", len(corpus), err)
+	}
+
+	trained, err := TrainClassifier(corpus, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(trained)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := ParseClassifier(data)
+	if err != nil {
+		t.Fatalf("ParseClassifier: %v", err)
+	}
+	for _, text := range []string{"share the OTP now", "dinner tonight", "hello"} {
+		// Summing the tokens in map order leaves the last bits to chance
+		if a, b := trained.Probability(text), loaded.Probability(text); math.Abs(a-b) > 1e-12 {
+			t.Errorf("%q: %v before saving, %v after", text, a, b)
+		}
+	}
+	if _, err := ParseClassifier([]byte(`{"version": 1, "docs": [0, 2], "counts": {}}`)); err == nil {
+		t.Error("model without ham messages or tokens accepted")
+	}
+}
+
+func TestBlendScalesModelPoints(t *testing.T) {
+	m, err := TrainClassifier(tinyCorpus, 1, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs := &RuleSet{Classifier: ClassifierBlend{Weight: 20, Flag: "model", FlagAt: 0.9}}
+	for _, text := range []string{"share the OTP, account blocked now", "dinner tonight, come home", "zebra quantum"} {
+		ind := ScamIndicators{Score: 10}
+		rs.Blend(text, m, &ind)
+		p := m.Probability(text)
+		points := int(math.Round(20 * (2*p - 1)))
+		if ind.ModelPoints != points || ind.Score != 10+points || ind.ModelVersion != 4 || math.Abs(ind.ModelProbability-p) > 1e-12 {
+			t.Errorf("%q (p %.3f): points %d score %d version %d, want %d, %d, 4", text, p, ind.ModelPoints, ind.Score, ind.ModelVersion, points, 10+points)
+		}
+		if ind.Has("model") != (p >= 0.9) {
+			t.Errorf("%q (p %.3f): model flag %v", text, p, ind.Has("model"))
+		}
+		if points < -20 || points > 20 {
+			t.Errorf("%q: %d points outside ±20", text, points)
+		}
+	}
+
+	ind := ScamIndicators{Score: 10}
+	rs.Blend("share the OTP", nil, &ind)
+	if ind.Score != 10 || ind.ModelVersion != 0 {
+		t.Errorf("blend without a model changed the indicators: %+v", ind)
+	}
+}
@@ -0,0 +1,110 @@
+package internal
+
+import (
+	"regexp"
+	"strings"
+)
+
+// DialogueAct is what a scammer message is trying to do
+type DialogueAct string
+
+const (
+	ActRequestCredential DialogueAct = "REQUEST_CREDENTIAL" // Wants an OTP, PIN, password or CVV
+	ActRequestPayment    DialogueAct = "REQUEST_PAYMENT"    // Wants money sent somewhere
+	ActRequestProof      DialogueAct = "REQUEST_PROOF"      // Wants a screenshot or receipt of a payment
+	ActThreaten          DialogueAct = "THREATEN"           // Account blocks, arrests, penalties
+	ActProvideInfo       DialogueAct = "PROVIDE_INFO"       // Shares a UPI ID, number, link, name...
+	ActAskQuestion       DialogueAct = "ASK_QUESTION"       // Asks the victim something
+	ActExpressSuspicion  DialogueAct = "EXPRESS_SUSPICION"  // Suspects a bot or time wasting
+	ActOther             DialogueAct = "OTHER"
+)
+
+// actPriority decides the primary act when a message does several things.
+// Demands the reply must answer come before information we merely collect.
+var actPriority = []DialogueAct{
+	ActRequestCredential,
+	ActExpressSuspicion,
+	ActRequestProof,
+	ActRequestPayment,
+	ActProvideInfo,
+	ActThreaten,
+	ActAskQuestion,
+}
+
+const (
+	requestVerbs   = `share|send|tell|give|enter|provide|read|forward|type|confirm|say|bhejo|bhejiye|batao|bataiye|bolo|dijiye|de\s*do`
+	proofWord      = `screenshot|screen\s*shot|receipt|proof|payment\s*slip|utr`
+	credentialWord = `otp|one\s*time\s*password|m?pin|upi\s*pin|atm\s*pin|cvv|password|passcode|verification\s*code|security\s*code|code`
+)
+
+var (
+	regexActCredential = regexp.MustCompile(`(?i)\b(` + requestVerbs + `)\b[^.?!]{0,40}\b(` + credentialWord + `)\b|\b(` + credentialWord + `)\b[^.?!]{0,40}\b(` + requestVerbs + `)\b|\bwhat\s+is\s+(the|your)\s+(` + credentialWord + `)\b`)
+
+	regexActPayment = regexp.MustCompile(`(?i)\b(pay|transfer|send|deposit|bhejo|bhejiye|jama\s*karo)\b[^.?!]{0,40}(\b(rs\.?|inr|rupees|amount|money|fee|fees|charges?|fine|payment|paise|paisa)\b|₹)|\b(payment|fee|charges?|fine|penalty)\b[^.?!]{0,30}\b(pay|karo|kijiye|now|immediately|turant)\b|(₹|\brs\.?)\s*\d`)
+
+	regexActProof = regexp.MustCompile(`(?i)\b(` + requestVerbs + `|show|upload|dikhao)\b[^.?!]{0,40}\b(` + proofWord + `)\b|\b(` + proofWord + `)\b[^.?!]{0,40}\b(` + requestVerbs + `|show|upload|dikhao)\b`)
+
+	regexActThreat = regexp.MustCompile(`(?i)\b(block(ed)?|suspend(ed)?|frozen|freeze|deactivat\w*|terminat\w*|arrest(ed)?|warrant|legal\s*action|police|fir|penalty|jail|court|seize[d]?|band\s*ho\s*jayega|last\s*warning|final\s*warning)\b`)
+
+	regexActIdentity = regexp.MustCompile(`(?i)\b(my\s+name\s+is|this\s+is\s+(officer|inspector|mr\.?|mrs\.?|ms\.?)|i\s+am\s+(officer|inspector|calling\s+from)|my\s+(employee|badge|staff)\s*(id|number|no))\b`)
+
+	regexActQuestion = regexp.MustCompile(`(?i)\?|^\s*(what|which|where|when|why|who|how|do|does|did|are|is|have|has|can|could|will|would|kya|kaun|kab|kahan|kyun)\b`)
+
+	regexActSuspicion = regexp.MustCompile(`(?i)\b(are\s+you\s+(a\s+)?(bot|robot|machine|ai|computer|recording|joking|kidding|serious)|(wasting|waste)\s+(my\s+)?time|time\s*pass|stop\s+(playing|acting|asking|pretending)|don'?t\s+(act|pretend|play)|you\s+are\s+(lying|fake|not\s+serious|playing)|why\s+(are\s+you|so\s+many)\s+(asking|questions)|tum\s+bot\s+ho|natak\s+mat\s+karo)\b`)
+)
+
+// DialogueActs is the classification of one scammer message
+type DialogueActs struct {
+	Primary DialogueAct   // The act the reply should react to
+	Acts    []DialogueAct // Every act found, in priority order
+}
+
+// Has reports whether the message performed the act
+func (d DialogueActs) Has(act DialogueAct) bool {
+	for _, a := range d.Acts {
+		if a == act {
+			return true
+		}
+	}
+	return false
+}
+
+// ClassifyDialogueAct finds what a scammer message asks for or does
+func ClassifyDialogueAct(text string) DialogueActs {
+	found := map[DialogueAct]bool{
+		ActRequestCredential: regexActCredential.MatchString(text),
+		ActRequestPayment:    regexActPayment.MatchString(text),
+		ActRequestProof:      regexActProof.MatchString(text),
+		ActThreaten:          regexActThreat.MatchString(text),
+		ActProvideInfo:       providesInfo(text),
+		ActAskQuestion:       regexActQuestion.MatchString(strings.TrimSpace(text)),
+		ActExpressSuspicion:  regexActSuspicion.MatchString(text),
+	}
+
+	result := DialogueActs{Primary: ActOther}
+	for _, act := range actPriority {
+		if found[act] {
+			result.Acts = append(result.Acts, act)
+		}
+	}
+	if len(result.Acts) > 0 {
+		result.Primary = result.Acts[0]
+	}
+	return result
+}
+
+// providesInfo reports whether the message contains something we collect
+func providesInfo(text string) bool {
+	lower := strings.ToLower(text)
+	for _, suffix := range upiSuffixes {
+		if strings.Contains(lower, suffix) {
+			return true
+		}
+	}
+	for _, re := range []*regexp.Regexp{UPIRegex, PhoneRegex, PhishingLinkRegex, EmailRegex, IFSCRegex, CardNumberRegex, regexActIdentity} {
+		if re.MatchString(text) {
+			return true
+		}
+	}
+	return false
+}
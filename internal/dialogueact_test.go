@@ -0,0 +1,35 @@
+package internal
+
+import "testing"
+
+func TestClassifyDialogueAct(t *testing.T) {
+	tests := []struct {
+		text    string
+		primary DialogueAct
+		also    []DialogueAct
+	}{
+		{"Please share the OTP you just received.", ActRequestCredential, nil},
+		{"OTP jaldi bhejo warna account band ho jayega", ActRequestCredential, []DialogueAct{ActThreaten}},
+		{"Pay Rs 499 processing fee immediately to release the refund.", ActRequestPayment, nil},
+		{"Send me the screenshot of the payment.", ActRequestProof, nil},
+		{"Your account will be blocked and police will arrest you.", ActThreaten, nil},
+		{"My UPI id is refund.desk@okaxis", ActProvideInfo, nil},
+		{"My name is Rahul Verma from the SBI fraud team.", ActProvideInfo, nil},
+		{"Which branch do you hold your account in?", ActAskQuestion, nil},
+		{"Are you a bot? Stop wasting my time.", ActExpressSuspicion, nil},
+		{"Okay sir, I am waiting.", ActOther, nil},
+		// Demands come first even when the message also shares details
+		{"Pay Rs 200 to refund.desk@okaxis now", ActRequestPayment, []DialogueAct{ActProvideInfo}},
+	}
+	for _, tt := range tests {
+		got := ClassifyDialogueAct(tt.text)
+		if got.Primary != tt.primary {
+			t.Errorf("%q: primary %s, want %s (acts %v)", tt.text, got.Primary, tt.primary, got.Acts)
+		}
+		for _, act := range tt.also {
+			if !got.Has(act) {
+				t.Errorf("%q: acts %v, want %s among them", tt.text, got.Acts, act)
+			}
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package internal
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Fact is something the agent has said about itself
+type Fact struct {
+	Key    string // e.g. "bank", "account_last4", "location", "spouse"
+	Value  string
+	Turn   int
+	Source string // "persona" for seeded facts, "agent" for claims made in replies
+}
+
+// FactLedger records the victim's claims so later replies can stay consistent
+type FactLedger struct {
+	Facts []Fact
+}
+
+// FactConflict pairs a candidate claim with the earlier fact it contradicts
+type FactConflict struct {
+	Claim    Fact
+	Existing Fact
+}
+
+func (c FactConflict) String() string {
+	return fmt.Sprintf("%s %q contradicts %q said on turn %d", c.Claim.Key, c.Claim.Value, c.Existing.Value, c.Existing.Turn)
+}
+
+// transientFactTurns is how long a transient fact (where the victim is right now)
+// stays binding before the victim may plausibly have moved on
+const transientFactTurns = 3
+
+var transientFacts = map[string]bool{"location": true}
+
+var bankAliases = map[string]string{
+	"state bank of india":  "sbi",
+	"state bank":           "sbi",
+	"punjab national bank": "pnb",
+	"hdfc bank":            "hdfc",
+	"icici bank":           "icici",
+	"axis bank":            "axis",
+	"kotak mahindra bank":  "kotak",
+	"kotak bank":           "kotak",
+	"canara":               "canara bank",
+	"baroda":               "bank of baroda",
+	"bob":                  "bank of baroda",
+}
+
+var (
+	claimAccountEnding = regexp.MustCompile(`(?i)\b(account|a/c|card)\b[\w\s]{0,20}?\b(?:ending|ends)\s*(?:with|in)?\s*(\d{3,4})\b`)
+	claimBank          = regexp.MustCompile(`(?i)\bmy\s+(state\s+bank(?:\s+of\s+india)?|sbi|hdfc(?:\s+bank)?|icici(?:\s+bank)?|axis(?:\s+bank)?|kotak(?:\s+mahindra)?(?:\s+bank)?|canara(?:\s+bank)?|punjab\s+national\s+bank|pnb|bank\s+of\s+baroda|baroda|bob)\s+(?:bank\s+)?(account|passbook|card|branch|app)\b`)
+	claimLocation      = regexp.MustCompile(`(?i)\bi(?:'m|\s+am)\s+(?:at|in)\s+(?:the\s+)?(market|home|hospital|temple|office|station|bank|clinic|pharmacy|my\s+(?:son|daughter)'s\s+(?:house|place))\b`)
+	claimSpouse        = regexp.MustCompile(`(?i)\bmy\s+(late\s+)?(wife|husband)\b`)
+	claimChild         = regexp.MustCompile(`(?i)\bmy\s+(son|daughter)\b`)
+	claimName          = regexp.MustCompile(`\b[Mm]y name is\s+([A-Z][a-z]+(?:\s+[A-Z][a-z]+)?)\b`)
+	claimCity          = regexp.MustCompile(`\b[Ii]\s+live\s+in\s+([A-Z][a-z]+(?:\s+[A-Z][a-z]+)?)\b`) // A proper noun, not "a small house"
+	claimAge           = regexp.MustCompile(`(?i)\bi\s*(?:'m|am)\s+(\d{2})\s+years?\s+old\b`)
+)
+
+// ExtractClaims finds the facts a reply asserts about the victim
+func ExtractClaims(reply string) []Fact {
+	var facts []Fact
+	for _, m := range claimAccountEnding.FindAllStringSubmatch(reply, -1) {
+		key := "account_last4"
+		if strings.EqualFold(m[1], "card") {
+			key = "card_last4"
+		}
+		facts = append(facts, Fact{Key: key, Value: m[2]})
+	}
+	for _, m := range claimBank.FindAllStringSubmatch(reply, -1) {
+		facts = append(facts, Fact{Key: "bank", Value: normalizeBank(m[1])})
+	}
+	for _, m := range claimLocation.FindAllStringSubmatch(reply, -1) {
+		facts = append(facts, Fact{Key: "location", Value: strings.ToLower(strings.Join(strings.Fields(m[1]), " "))})
+	}
+	for _, m := range claimSpouse.FindAllStringSubmatch(reply, -1) {
+		if m[1] != "" {
+			facts = append(facts, Fact{Key: "spouse", Value: "none"})
+			continue
+		}
+		facts = append(facts, Fact{Key: "spouse", Value: strings.ToLower(m[2])})
+	}
+	for _, m := range claimChild.FindAllStringSubmatch(reply, -1) {
+		facts = append(facts, Fact{Key: "child", Value: strings.ToLower(m[1])})
+	}
+	for _, m := range claimName.FindAllStringSubmatch(reply, -1) {
+		facts = append(facts, Fact{Key: "name", Value: m[1]})
+	}
+	for _, m := range claimCity.FindAllStringSubmatch(reply, -1) {
+		facts = append(facts, Fact{Key: "city", Value: m[1]})
+	}
+	for _, m := range claimAge.FindAllStringSubmatch(reply, -1) {
+		facts = append(facts, Fact{Key: "age", Value: m[1]})
+	}
+	return facts
+}
+
+func normalizeBank(name string) string {
+	name = strings.ToLower(strings.Join(strings.Fields(name), " "))
+	if alias, ok := bankAliases[name]; ok {
+		return alias
+	}
+	return name
+}
+
+// PersonaFacts returns the persona's fixed facts for seeding a ledger
+func PersonaFacts(p Persona) []Fact {
+	spouse := p.Spouse
+	if spouse == "" {
+		spouse = "none"
+	}
+	return []Fact{
+		{Key: "name", Value: p.Name, Source: "persona"},
+		{Key: "age", Value: strconv.Itoa(p.Age), Source: "persona"},
+		{Key: "city", Value: p.City, Source: "persona"},
+		{Key: "bank", Value: normalizeBank(p.Bank), Source: "persona"},
+		{Key: "spouse", Value: spouse, Source: "persona"},
+		{Key: "child", Value: p.Child, Source: "persona"},
+	}
+}
+
+// latest returns the most recent fact for a key
+func (l *FactLedger) latest(key string) (Fact, bool) {
+	if l == nil {
+		return Fact{}, false
+	}
+	for i := len(l.Facts) - 1; i >= 0; i-- {
+		if l.Facts[i].Key == key {
+			return l.Facts[i], true
+		}
+	}
+	return Fact{}, false
+}
+
+// Conflicts returns the claims in a candidate reply that contradict the ledger
+func (l *FactLedger) Conflicts(reply string, turn int) []FactConflict {
+	var conflicts []FactConflict
+	for _, claim := range ExtractClaims(reply) {
+		existing, ok := l.latest(claim.Key)
+		if !ok || strings.EqualFold(existing.Value, claim.Value) {
+			continue
+		}
+		if claim.Key == "name" && strings.HasPrefix(strings.ToLower(existing.Value), strings.ToLower(claim.Value)) {
+			continue // First name only
+		}
+		if transientFacts[claim.Key] && turn-existing.Turn > transientFactTurns {
+			continue
+		}
+		conflicts = append(conflicts, FactConflict{Claim: claim, Existing: existing})
+	}
+	return conflicts
+}
+
+// Record adds the claims made in a reply the agent actually sent
+func (l *FactLedger) Record(reply string, turn int, source string) {
+	for _, claim := range ExtractClaims(reply) {
+		if existing, ok := l.latest(claim.Key); ok && strings.EqualFold(existing.Value, claim.Value) && !transientFacts[claim.Key] {
+			continue
+		}
+		claim.Turn = turn
+		claim.Source = source
+		l.Facts = append(l.Facts, claim)
+	}
+}
+
+// Describe lists the facts established in conversation (persona facts are
+// already part of the persona description) for LLM prompts
+func (l *FactLedger) Describe(turn int) []string {
+	if l == nil {
+		return nil
+	}
+	var items []string
+	for _, f := range l.Facts {
+		if f.Source == "persona" {
+			continue
+		}
+		if transientFacts[f.Key] && turn-f.Turn > transientFactTurns {
+			continue
+		}
+		items = append(items, fmt.Sprintf("%s: %s (turn %d)", strings.ReplaceAll(f.Key, "_", " "), f.Value, f.Turn))
+	}
+	return items
+}
@@ -0,0 +1,32 @@
+package internal
+
+import "testing"
+
+func TestFactConflicts(t *testing.T) {
+	ledger := &FactLedger{Facts: PersonaFacts(DefaultPersona)}
+	ledger.Record("I'm at the market now, is that fine?", 2, "agent")
+
+	for reply, want := range map[string]string{
+		"I live in " + DefaultPersona.City + ", which branch is it?": "",
+		"I live in Pune, which branch is it?":                        "city",
+		"I live in Navi Mumbai, which branch is it?":                 "city",
+		"I live in a small house, shall I come?":                     "",
+		"My wife is calling me, can you wait?":                       "",
+		"My husband handles this, can he call?":                      "spouse",
+		"Is my HDFC bank account the one?":                           "bank",
+		"I am at home now, what next?":                               "location",
+	} {
+		var got string
+		for _, c := range ledger.Conflicts(reply, 3) {
+			got = c.Claim.Key
+		}
+		if got != want {
+			t.Errorf("%q: conflict on %q, want %q", reply, got, want)
+		}
+	}
+
+	// Where the victim is stops binding after a few turns
+	if c := ledger.Conflicts("I am at home now, what next?", 2+transientFactTurns+1); len(c) != 0 {
+		t.Errorf("stale location still conflicts: %v", c)
+	}
+}
@@ -0,0 +1,252 @@
+package internal
+
+import (
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// GuardrailConfig controls how LLM replies are validated before they are sent
+type GuardrailConfig struct {
+	MaxWords         int  // Word limit from the system prompt
+	RequireQuestion  bool // Reply must end with a question
+	MaxRegenerations int  // Extra LLM attempts before falling back to templates
+}
+
+// GuardrailConfigFromEnv reads GUARDRAIL_MAX_WORDS (default 40),
+// GUARDRAIL_REQUIRE_QUESTION (default true) and GUARDRAIL_MAX_REGENERATIONS (default 2)
+func GuardrailConfigFromEnv() GuardrailConfig {
+	cfg := GuardrailConfig{
+		MaxWords:         envInt("GUARDRAIL_MAX_WORDS", 40),
+		RequireQuestion:  true,
+		MaxRegenerations: envInt("GUARDRAIL_MAX_REGENERATIONS", 2),
+	}
+	if v := os.Getenv("GUARDRAIL_REQUIRE_QUESTION"); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			cfg.RequireQuestion = b
+		}
+	}
+	return cfg
+}
+
+// minScriptShare is the fraction of letters that must be in the locale's script
+const minScriptShare = 0.5
+
+// Violation describes a rule a candidate reply broke
+type Violation struct {
+	Rule   string
+	Detail string
+}
+
+func (v Violation) String() string {
+	return v.Rule + ": " + v.Detail
+}
+
+var (
+	// Words the system prompt forbids
+	regexBannedReplyWords = regexp.MustCompile(`(?i)\b(scam\w*|fraud\w*|fake|suspicious)\b`)
+
+	// The model stepping out of the victim role
+	regexBreakCharacter = regexp.MustCompile(`(?i)\b(as an ai|an ai (language )?model|language model|i am an ai|i'm an ai|i am a bot|i'm a bot|chatgpt|openai|as an assistant|as a helpful assistant|system prompt|i (cannot|can't|won't) (help|assist|comply)|i'm sorry, but i)\b`)
+
+	regexDigitRun   = regexp.MustCompile(`\d[\d\s\-]*\d|\d`)
+	regexReplyURL   = regexp.MustCompile(`(?i)(?:https?://|www\.)\S+`)
+	regexPAN        = regexp.MustCompile(`\b[A-Z]{5}\d{4}[A-Z]\b`)
+	regexReplyEmail = regexp.MustCompile(`(?i)\b[a-z0-9._%+\-]+@[a-z0-9.\-]+\b`)
+
+	// Wording that makes a short number read as a one-time code
+	regexCodeWord = regexp.MustCompile(`(?i)\b(otp|one\s*time\s*password|m?pin|cvv|passcode|password|code)\b`)
+)
+
+// codeWordWindow is how many bytes either side of a number are searched for code wording
+const codeWordWindow = 30
+
+// ValidateReply checks a candidate LLM reply against the persona rules. Numbers
+// and addresses that already appear in the conversation (e.g. the scammer's
+// own UPI ID read back to them) are allowed; anything the model invented is not.
+func ValidateReply(reply string, req ResponseRequest, cfg GuardrailConfig) []Violation {
+	var violations []Violation
+	trimmed := strings.TrimSpace(reply)
+
+	// Identifiers read back to the scammer (e.g. fraud.help@ybl) may contain banned words
+	prose := regexReplyURL.ReplaceAllString(regexReplyEmail.ReplaceAllString(trimmed, " "), " ")
+	if m := regexBannedReplyWords.FindString(prose); m != "" {
+		violations = append(violations, Violation{"banned_word", "mentions \"" + m + "\""})
+	}
+	if m := regexBreakCharacter.FindString(trimmed); m != "" {
+		violations = append(violations, Violation{"break_character", "says \"" + m + "\""})
+	}
+	if cfg.MaxWords > 0 {
+		if n := len(strings.Fields(trimmed)); n > cfg.MaxWords {
+			violations = append(violations, Violation{"word_limit", strconv.Itoa(n) + " words, limit is " + strconv.Itoa(cfg.MaxWords)})
+		}
+	}
+	if cfg.RequireQuestion && !strings.HasSuffix(strings.TrimRight(trimmed, " \"')"), "?") {
+		violations = append(violations, Violation{"no_question", "does not end with a question"})
+	}
+	// Words like "UPI" or "OTP" are fine, but most of the reply must be in the expected script
+	if script := expectedScript(req.Locale); script != nil && scriptShare(trimmed, script) < minScriptShare {
+		violations = append(violations, Violation{"wrong_language", LanguageInstruction(req.Locale)})
+	}
+
+	// A read-back must carry its deliberate mistake, not the value the scammer sent
+	if rb := req.ReadBack; rb != nil && req.Intent == IntentReadBack && !containsReadBack(trimmed, rb) {
+		violations = append(violations, Violation{"read_back", "does not read back " + rb.Altered + " exactly"})
+	}
+
+	// Handing over fake credentials only works if the values are the minted ones
+	if req.Intent == IntentGiveHoneytoken && len(req.Honeytokens) > 0 && !containsAnyHoneytoken(trimmed, req.Honeytokens) {
+		violations = append(violations, Violation{"honeytoken", "does not give " + strings.Join(describeHoneytokens(req.Honeytokens), ", ") + " exactly"})
+	}
+
+	if c := req.Canary; c != nil && req.Intent == IntentSendCanary && !strings.Contains(trimmed, c.URL) {
+		violations = append(violations, Violation{"canary", "does not include the link " + c.URL + " exactly"})
+	}
+
+	for _, c := range req.Facts.Conflicts(trimmed, req.TurnCount) {
+		violations = append(violations, Violation{"fact_conflict", c.String()})
+	}
+
+	known := conversationText(req)
+	knownRuns := digitRuns(known)
+	for _, loc := range regexDigitRun.FindAllStringIndex(trimmed, -1) {
+		run := trimmed[loc[0]:loc[1]]
+		digits := extractDigits(run)
+		if len(digits) < 4 || knownDigitRun(digits, knownRuns) {
+			continue
+		}
+		switch {
+		case len(digits) >= 13 && len(digits) <= 19 && luhnValid(digits):
+			violations = append(violations, Violation{"card_number", "invented card-like number " + run})
+		case len(digits) <= 8 && nearCodeWord(trimmed, loc[0], loc[1]):
+			violations = append(violations, Violation{"otp", "invented code " + run})
+		case len(digits) <= 8:
+			violations = append(violations, Violation{"unknown_number", "invented number " + run})
+		case len(digits) == 12:
+			violations = append(violations, Violation{"pii", "Aadhaar-like number " + run})
+		default:
+			violations = append(violations, Violation{"pii", "invented number " + run})
+		}
+	}
+	if m := regexPAN.FindString(trimmed); m != "" && !strings.Contains(known, m) {
+		violations = append(violations, Violation{"pii", "PAN-like value " + m})
+	}
+	knownLower := strings.ToLower(known)
+	for _, m := range regexReplyEmail.FindAllString(trimmed, -1) {
+		if !strings.Contains(knownLower, strings.ToLower(m)) {
+			violations = append(violations, Violation{"pii", "invented address " + m})
+		}
+	}
+
+	return violations
+}
+
+// nearCodeWord reports whether OTP, PIN or code wording surrounds text[start:end]
+func nearCodeWord(text string, start, end int) bool {
+	from, to := max(start-codeWordWindow, 0), min(end+codeWordWindow, len(text))
+	return regexCodeWord.MatchString(text[from:to])
+}
+
+// digitRuns collects the numbers in the text, spacing and dashes removed
+func digitRuns(text string) map[string]bool {
+	runs := map[string]bool{}
+	for _, run := range regexDigitRun.FindAllString(text, -1) {
+		runs[extractDigits(run)] = true
+	}
+	return runs
+}
+
+// knownDigitRun reports whether digits is one of the known numbers, or its
+// ending as in "account ending 4421"
+func knownDigitRun(digits string, runs map[string]bool) bool {
+	if runs[digits] {
+		return true
+	}
+	for run := range runs {
+		if strings.HasSuffix(run, digits) {
+			return true
+		}
+	}
+	return false
+}
+
+// conversationText joins everything said so far by either side, the facts
+// already claimed, the captured intel and the values we hand over on purpose,
+// so restating one of them is not taken for a leak
+func conversationText(req ResponseRequest) string {
+	var sb strings.Builder
+	sb.WriteString(req.ScammerMessage)
+	for _, turn := range req.History {
+		sb.WriteString("\n" + turn.Text)
+	}
+	if req.Facts != nil {
+		for _, f := range req.Facts.Facts {
+			sb.WriteString("\n" + f.Value)
+		}
+	}
+	for _, item := range describeIntel(req.Intel) {
+		sb.WriteString("\n" + item)
+	}
+	if req.ReadBack != nil {
+		sb.WriteString("\n" + req.ReadBack.Altered)
+	}
+	for _, t := range req.Honeytokens {
+		sb.WriteString("\n" + t.Value + " " + t.Expiry)
+	}
+	if req.Canary != nil {
+		sb.WriteString("\n" + req.Canary.URL)
+	}
+	return sb.String()
+}
+
+// containsAnyHoneytoken reports whether the reply hands over at least one of the tokens
+func containsAnyHoneytoken(reply string, tokens []Honeytoken) bool {
+	for _, t := range tokens {
+		if containsHoneytoken(reply, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsReadBack reports whether the reply contains the altered value,
+// ignoring case and, for numbers, spacing
+func containsReadBack(reply string, rb *ReadBack) bool {
+	if rb.Kind == "upi" {
+		return strings.Contains(strings.ToLower(reply), strings.ToLower(rb.Altered))
+	}
+	digits := extractDigits(rb.Altered)
+	if rb.Kind == "phone" && len(digits) > 10 {
+		digits = digits[len(digits)-10:]
+	}
+	return strings.Contains(extractDigits(reply), digits)
+}
+
+// luhnValid reports whether a digit string passes the Luhn checksum
+func luhnValid(digits string) bool {
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return len(digits) > 0 && sum%10 == 0
+}
+
+// correctionMessage tells the model which rules its previous reply broke
+func correctionMessage(violations []Violation) string {
+	parts := make([]string, 0, len(violations))
+	for _, v := range violations {
+		parts = append(parts, v.String())
+	}
+	return "Your previous reply broke these rules: " + strings.Join(parts, "; ") +
+		". Write a new reply that stays in character as the worried victim, never invents codes, card numbers or personal details, and follows every rule."
+}
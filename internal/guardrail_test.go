@@ -0,0 +1,96 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+)
+
+var testGuardrail = GuardrailConfig{MaxWords: 40, RequireQuestion: true}
+
+// violated lists the rules the reply breaks
+func violated(reply string, req ResponseRequest) string {
+	var rules []string
+	for _, v := range ValidateReply(reply, req, testGuardrail) {
+		rules = append(rules, v.Rule)
+	}
+	return strings.Join(rules, ",")
+}
+
+func TestValidateReplyStyle(t *testing.T) {
+	req := ResponseRequest{ScammerMessage: "Your account is blocked", Facts: &FactLedger{}}
+	for reply, want := range map[string]string{
+		"Oh no, which branch are you calling from?":                   "",
+		"Is this a scam? Who are you?":                                "banned_word",
+		"As an AI language model I cannot help with that, can I?":     "break_character",
+		"Okay I will wait here for you.":                              "no_question",
+		strings.Repeat("please ", 45) + "tell me?":                    "word_limit",
+		"Should I send it to fraud.help@ybl or to another account?":   "pii",
+		"Should I call the number on www.fraud-alert.example please?": "",
+	} {
+		if got := violated(reply, req); got != want {
+			t.Errorf("%q: violations %q, want %q", reply, got, want)
+		}
+	}
+}
+
+func TestValidateReplyNumbers(t *testing.T) {
+	req := ResponseRequest{
+		ScammerMessage: "Call 98765 43210 and pay Rs 4500 now",
+		Facts:          &FactLedger{},
+	}
+	for reply, want := range map[string]string{
+		"Is it 9876543210, sir?":                    "",
+		"Shall I call 98765-43210?":                 "",
+		"Do I pay 4500 to you?":                     "",
+		"My account ending 3210, is it the same?":   "",
+		"The code is 1045, is that right?":          "otp",
+		"The code is 0450, is that right?":          "otp",
+		"The code is 731942, is that right?":        "otp",
+		"Is my PIN 4471 needed also?":               "otp",
+		"My flat is number 2204, shall I come?":     "unknown_number",
+		"Is my Aadhaar 2345 6789 0123 needed?":      "pii",
+		"Should I read 4539 1488 0343 6467 to you?": "card_number",
+	} {
+		if got := violated(reply, req); got != want {
+			t.Errorf("%q: violations %q, want %q", reply, got, want)
+		}
+	}
+}
+
+func TestKnownDigitRun(t *testing.T) {
+	runs := digitRuns("Call 98765 43210, pay 4500 by 12-05")
+	for digits, want := range map[string]bool{
+		"9876543210": true,
+		"3210":       true,
+		"43210":      true,
+		"4500":       true,
+		"1205":       true,
+		"1045":       false, // Spans two numbers
+		"9876":       false, // The start of a number is not its ending
+		"500":        true,
+	} {
+		if got := knownDigitRun(digits, runs); got != want {
+			t.Errorf("knownDigitRun(%s) = %v, want %v", digits, got, want)
+		}
+	}
+}
+
+func TestValidateReplyRestatesEarlierClaims(t *testing.T) {
+	req := ResponseRequest{
+		ScammerMessage: "Tell me your account details fast",
+		History: []ChatTurn{
+			{Sender: SenderScammer, Text: "Which account do you have?"},
+			{Sender: SenderAgent, Text: "My pension comes to account ending 4421, is that the one?"},
+		},
+		Facts: &FactLedger{Facts: []Fact{{Key: "age", Value: "67", Turn: 0, Source: "persona"}, {Key: "card_last4", Value: "8812", Turn: 2, Source: "agent"}}},
+	}
+	for reply, want := range map[string]string{
+		"Yes, the account ending 4421, shall I read it again?": "",
+		"My card ending 8812 also, is that needed?":            "",
+		"Is account ending 5521 the one you mean?":             "unknown_number",
+	} {
+		if got := violated(reply, req); got != want {
+			t.Errorf("%q: violations %q, want %q", reply, got, want)
+		}
+	}
+}
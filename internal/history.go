@@ -0,0 +1,162 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Senders used in the session transcript
+const (
+	SenderScammer = "scammer"
+	SenderAgent   = "agent"
+)
+
+// ChatTurn is a single sender-tagged message of the conversation
+type ChatTurn struct {
+	Sender string
+	Text   string
+}
+
+// NormalizeSender maps the sender labels used by the platform onto the
+// transcript senders. The platform calls the honeypot side "user".
+func NormalizeSender(sender string) string {
+	switch strings.ToLower(strings.TrimSpace(sender)) {
+	case "user", "agent", "assistant", "honeypot", "victim", "me":
+		return SenderAgent
+	default:
+		return SenderScammer
+	}
+}
+
+// HistoryBudget limits how much of the transcript is sent to the LLM
+type HistoryBudget struct {
+	RecentTokens  int // Budget for verbatim recent turns
+	SummaryTokens int // Budget for the summary of older turns
+}
+
+// HistoryBudgetFromEnv reads LLM_HISTORY_TOKEN_BUDGET (default 600) and
+// LLM_SUMMARY_TOKEN_BUDGET (default 150)
+func HistoryBudgetFromEnv() HistoryBudget {
+	return HistoryBudget{
+		RecentTokens:  envInt("LLM_HISTORY_TOKEN_BUDGET", 600),
+		SummaryTokens: envInt("LLM_SUMMARY_TOKEN_BUDGET", 150),
+	}
+}
+
+// estimateTokens approximates the token count of a text (about 4 characters per token)
+func estimateTokens(text string) int {
+	return (utf8.RuneCountInString(text) + 3) / 4
+}
+
+// BuildHistoryMessages turns the transcript into user/assistant chat turns.
+// The newest turns are kept verbatim within the recent budget; anything older
+// is condensed into a single user message within the summary budget, never a
+// system one, since it quotes the scammer.
+func BuildHistoryMessages(history []ChatTurn, budget HistoryBudget) []ChatMessage {
+	if len(history) == 0 {
+		return nil
+	}
+
+	// Walk backwards until the verbatim budget is used up
+	start := len(history)
+	used := 0
+	for start > 0 {
+		cost := estimateTokens(history[start-1].Text)
+		if used+cost > budget.RecentTokens && start < len(history) {
+			break
+		}
+		used += cost
+		start--
+	}
+
+	var messages []ChatMessage
+	if start > 0 {
+		if summary := summarizeTurns(history[:start], budget.SummaryTokens); summary != "" {
+			messages = append(messages, ChatMessage{Role: "user", Content: summary})
+		}
+	}
+
+	for _, turn := range history[start:] {
+		text := strings.TrimSpace(turn.Text)
+		if text == "" {
+			continue
+		}
+		role := "user"
+		if turn.Sender == SenderAgent {
+			role = "assistant"
+		} else {
+			text = delimitScammerText(text)
+		}
+		// Merge consecutive messages from the same side so roles alternate
+		if n := len(messages); n > 0 && messages[n-1].Role == role {
+			messages[n-1].Content += "\n" + text
+			continue
+		}
+		messages = append(messages, ChatMessage{Role: role, Content: text})
+	}
+	return messages
+}
+
+// summarizeTurns condenses older turns into short "Caller"/"Me" lines, keeping
+// the most recent ones when the budget is too small for all of them. The
+// caller's words stay delimited like in the recent turns.
+func summarizeTurns(turns []ChatTurn, budget int) string {
+	if budget <= 0 {
+		return ""
+	}
+
+	const header = "Summary of the earlier conversation:"
+	used := estimateTokens(header)
+	var lines []string
+	omitted := 0
+	for i := len(turns) - 1; i >= 0; i-- {
+		text := firstWords(turns[i].Text, 15)
+		if turns[i].Sender != SenderAgent {
+			text = delimitScammerText(text)
+		}
+		line := "- " + speakerLabel(turns[i].Sender) + ": " + text
+		cost := estimateTokens(line)
+		if used+cost > budget {
+			omitted = i + 1
+			break
+		}
+		used += cost
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+
+	// Restore chronological order
+	for i, j := 0, len(lines)-1; i < j; i, j = i+1, j-1 {
+		lines[i], lines[j] = lines[j], lines[i]
+	}
+	var sb strings.Builder
+	sb.WriteString(header)
+	if omitted > 0 {
+		sb.WriteString(fmt.Sprintf(" (%d older messages not shown)", omitted))
+	}
+	sb.WriteString("\n" + strings.Join(lines, "\n"))
+	return sb.String()
+}
+
+func speakerLabel(sender string) string {
+	if sender == SenderAgent {
+		return "Me"
+	}
+	return "Caller"
+}
+
+// firstWords shortens text to its first sentence, capped at maxWords words
+func firstWords(text string, maxWords int) string {
+	text = strings.TrimSpace(text)
+	if idx := strings.IndexAny(text, ".?!\n"); idx > 0 {
+		text = text[:idx+1]
+	}
+	words := strings.Fields(text)
+	if len(words) > maxWords {
+		return strings.Join(words[:maxWords], " ") + "..."
+	}
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,501 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"math/rand"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Honeytokens are fake credentials handed to a scammer who keeps demanding
+// them: the card networks' published test card numbers, OTPs and PINs of the
+// length asked for and decoy UPI IDs. Every value is recorded against
+// the session that issued it. Scammers reuse what they harvest, so a
+// honeytoken turning up in a message of another session ties the two
+// campaigns together.
+
+// Honeytoken kinds
+const (
+	HoneytokenOTP  = "otp"
+	HoneytokenPIN  = "pin"
+	HoneytokenCard = "card"
+	HoneytokenCVV  = "cvv"
+	HoneytokenUPI  = "upi"
+)
+
+// Honeytoken is a fake value minted for a session
+type Honeytoken struct {
+	Kind      string    `json:"kind"`
+	Value     string    `json:"value"`
+	Expiry    string    `json:"expiry,omitempty"` // MM/YY, cards only
+	SessionID string    `json:"sessionId"`        // Session the value was handed to
+	Turn      int       `json:"turn"`
+	Issued    time.Time `json:"issued"`
+}
+
+// traceable reports whether the value is distinctive enough to recognise in
+// other conversations. Four digit PINs and CVVs are handed over but never
+// matched, and neither are card numbers, which every session shares.
+func (h Honeytoken) traceable() bool {
+	return h.Kind != HoneytokenCard && len(honeytokenKey(h.Kind, h.Value)) >= 6
+}
+
+// HoneytokenSighting is a honeytoken found in a scammer's message
+type HoneytokenSighting struct {
+	Token  Honeytoken `json:"token"`
+	SeenIn string     `json:"seenIn"` // Session of the message
+	Turn   int        `json:"turn"`
+	Seen   time.Time  `json:"seen"`
+	Linked bool       `json:"linked"` // Seen in a session other than the one it was handed to
+}
+
+// HoneytokenReport lists the honeytokens of a session, or of every session
+type HoneytokenReport struct {
+	File           string               `json:"file,omitempty"`
+	Updated        time.Time            `json:"updated"`
+	Issued         []Honeytoken         `json:"issued"`
+	Sightings      []HoneytokenSighting `json:"sightings"`
+	LinkedSessions []string             `json:"linkedSessions,omitempty"` // Sessions sharing a honeytoken with this one
+}
+
+// honeytokenLabels name each kind in prompts and notes
+var honeytokenLabels = map[string]string{
+	HoneytokenOTP:  "OTP",
+	HoneytokenPIN:  "PIN",
+	HoneytokenCard: "card number",
+	HoneytokenCVV:  "CVV",
+	HoneytokenUPI:  "UPI ID",
+}
+
+// testCardNumbers are card numbers the networks and payment gateways publish
+// for testing. Only these are handed over: a random number in a test BIN
+// range may still belong to a real card, these are never issued to anyone.
+var testCardNumbers = []string{
+	"4111111111111111",
+	"4242424242424242",
+	"4012888888881881",
+	"4000056655665556",
+	"5555555555554444",
+	"5105105105105100",
+	"5200828282828210",
+	"2223003122003222",
+}
+
+// decoyUPISuffix is the handle of decoy UPI IDs. It is not registered to any
+// payment app, so money sent to a decoy bounces instead of reaching whoever
+// owns a look-alike ID on a real handle.
+const decoyUPISuffix = "okdecoy"
+
+var (
+	regexAskCard   = regexp.MustCompile(`(?i)\b(card\s*(number|no|details)|debit\s*card|credit\s*card|atm\s*card|expiry)\b`)
+	regexAskCVV    = regexp.MustCompile(`(?i)\b(cvv|cvc)\b`)
+	regexAskUPIID  = regexp.MustCompile(`(?i)\b(upi\s*id|vpa)\b`)
+	regexAskPIN    = regexp.MustCompile(`(?i)\b(m?pin|upi\s*pin|atm\s*pin)\b`)
+	regexAskOTP    = regexp.MustCompile(`(?i)\b(otp|one\s*time\s*password|code)\b`)
+	regexCodeLen   = regexp.MustCompile(`(?i)\b([4-8])\s*-?\s*digits?\b`)
+	regexDigits    = regexp.MustCompile(`\d+`)
+	regexUPIHandle = regexp.MustCompile(`(?i)[a-z0-9._\-]+@[a-z]+`)
+)
+
+// HoneytokenKinds returns what the message demands, OTP when it does not say
+func HoneytokenKinds(message string) []string {
+	var kinds []string
+	if regexAskCard.MatchString(message) {
+		kinds = append(kinds, HoneytokenCard)
+	}
+	if regexAskCVV.MatchString(message) {
+		kinds = append(kinds, HoneytokenCVV)
+	}
+	if regexAskUPIID.MatchString(message) {
+		kinds = append(kinds, HoneytokenUPI)
+	}
+	if regexAskPIN.MatchString(message) {
+		kinds = append(kinds, HoneytokenPIN)
+	}
+	if len(kinds) == 0 || regexAskOTP.MatchString(message) {
+		kinds = append(kinds, HoneytokenOTP)
+	}
+	return kinds
+}
+
+// HoneytokenRegistry records every honeytoken handed out and where they were
+// seen again. It is shared by all sessions.
+type HoneytokenRegistry struct {
+	mu        sync.RWMutex
+	issued    []Honeytoken
+	index     map[string]int // Traceable values by honeytokenKey, into issued
+	sightings []HoneytokenSighting
+	updated   time.Time
+
+	saveMu sync.Mutex
+	path   string // "" keeps the registry in memory only
+}
+
+var (
+	honeytokens     *HoneytokenRegistry
+	honeytokensOnce sync.Once
+	honeytokensErr  error
+)
+
+// LoadHoneytokens reads the registry from HONEYTOKEN_FILE (default
+// honeytokens.json, "off" to keep it in memory). Values must survive restarts,
+// a scammer may try a harvested card weeks later.
+func LoadHoneytokens() error {
+	honeytokensOnce.Do(func() {
+		path := os.Getenv("HONEYTOKEN_FILE")
+		switch path {
+		case "":
+			path = "honeytokens.json"
+		case "off":
+			path = ""
+		}
+		honeytokens = &HoneytokenRegistry{index: map[string]int{}, path: path}
+		honeytokensErr = honeytokens.load()
+		if honeytokensErr == nil {
+			log.Printf("Loaded honeytoken registry: %d issued, %d sightings", len(honeytokens.issued), len(honeytokens.sightings))
+		}
+	})
+	return honeytokensErr
+}
+
+// GetHoneytokens returns the shared registry, loading it on first use
+func GetHoneytokens() *HoneytokenRegistry {
+	if err := LoadHoneytokens(); err != nil {
+		log.Printf("Honeytoken registry failed to load, starting empty: %v", err)
+	}
+	return honeytokens
+}
+
+func (h *HoneytokenRegistry) load() error {
+	if h.path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(h.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var report HoneytokenReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return err
+	}
+	for _, t := range report.Issued {
+		if _, ok := honeytokenLabels[t.Kind]; !ok || t.Value == "" {
+			return fmt.Errorf("honeytoken %q has unknown kind %q", t.Value, t.Kind)
+		}
+		h.add(t)
+	}
+	h.sightings = report.Sightings
+	h.updated = report.Updated
+	return nil
+}
+
+// add appends t to issued and indexes it; the caller holds mu
+func (h *HoneytokenRegistry) add(t Honeytoken) {
+	h.issued = append(h.issued, t)
+	if t.traceable() {
+		h.index[honeytokenKey(t.Kind, t.Value)] = len(h.issued) - 1
+	}
+}
+
+// honeytokenKey normalises a value for matching: digits only for numbers,
+// lower case for UPI IDs
+func honeytokenKey(kind, value string) string {
+	if kind == HoneytokenUPI {
+		return strings.ToLower(value)
+	}
+	return extractDigits(value)
+}
+
+// Mint creates one honeytoken of each kind for the persona without recording
+// them; Issue records the ones the reply actually hands over. The message
+// decides the length of codes ("6 digit OTP"). With a nil r the shared random
+// source is used.
+func (h *HoneytokenRegistry) Mint(kinds []string, persona Persona, message string, r *rand.Rand) []Honeytoken {
+	if r == nil {
+		r = rng
+	}
+	length := 0
+	if m := regexCodeLen.FindStringSubmatch(message); m != nil {
+		length, _ = strconv.Atoi(m[1])
+	}
+
+	var tokens []Honeytoken
+	for _, kind := range kinds {
+		t := Honeytoken{Kind: kind}
+		// Retry the rare value that collides with an earlier honeytoken
+		for attempt := 0; attempt < 10; attempt++ {
+			switch kind {
+			case HoneytokenOTP:
+				t.Value = randomCode(r, orDefault(length, 6))
+			case HoneytokenPIN:
+				t.Value = randomCode(r, orDefault(length, 4))
+			case HoneytokenCVV:
+				t.Value = randomCode(r, 3)
+			case HoneytokenCard:
+				t.Value = testCardNumber(r)
+				t.Expiry = fmt.Sprintf("%02d/%02d", 1+r.Intn(12), (time.Now().Year()+1+r.Intn(4))%100)
+			case HoneytokenUPI:
+				t.Value = decoyUPI(persona, r)
+			}
+			if !t.traceable() || !h.known(t) {
+				break
+			}
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens
+}
+
+func (h *HoneytokenRegistry) known(t Honeytoken) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	_, ok := h.index[honeytokenKey(t.Kind, t.Value)]
+	return ok
+}
+
+func orDefault(n, def int) int {
+	if n == 0 {
+		return def
+	}
+	return n
+}
+
+// randomCode returns n random digits without a leading zero
+func randomCode(r *rand.Rand, n int) string {
+	b := make([]byte, n)
+	b[0] = byte('1' + r.Intn(9))
+	for i := 1; i < n; i++ {
+		b[i] = byte('0' + r.Intn(10))
+	}
+	return string(b)
+}
+
+// testCardNumber returns one of the published test card numbers, grouped in
+// fours the way it is printed on a card
+func testCardNumber(r *rand.Rand) string {
+	digits := testCardNumbers[r.Intn(len(testCardNumbers))]
+	var sb strings.Builder
+	for i := 0; i < len(digits); i++ {
+		if i > 0 && i%4 == 0 {
+			sb.WriteByte(' ')
+		}
+		sb.WriteByte(digits[i])
+	}
+	return sb.String()
+}
+
+// isTestCardNumber reports whether the value is one of the published test
+// card numbers, spacing ignored
+func isTestCardNumber(value string) bool {
+	digits := extractDigits(value)
+	for _, n := range testCardNumbers {
+		if digits == n {
+			return true
+		}
+	}
+	return false
+}
+
+// decoyUPI returns a UPI ID with the persona's name on the decoy handle, e.g.
+// ramesh.s4821@okdecoy
+func decoyUPI(persona Persona, r *rand.Rand) string {
+	if persona.Name == "" {
+		persona = DefaultPersona
+	}
+	names := strings.Fields(strings.ToLower(persona.Name))
+	handle := names[0]
+	if len(names) > 1 {
+		handle += "." + names[len(names)-1][:1]
+	}
+	return handle + strconv.Itoa(1000+r.Intn(9000)) + "@" + decoyUPISuffix
+}
+
+// Issue records the honeytokens that appear in the reply as handed to the
+// session and returns them
+func (h *HoneytokenRegistry) Issue(sessionID string, turn int, tokens []Honeytoken, reply string) []Honeytoken {
+	var issued []Honeytoken
+	for _, t := range tokens {
+		if !containsHoneytoken(reply, t) {
+			continue
+		}
+		t.SessionID, t.Turn, t.Issued = sessionID, turn, time.Now()
+		issued = append(issued, t)
+	}
+	if len(issued) == 0 {
+		return nil
+	}
+
+	h.mu.Lock()
+	for _, t := range issued {
+		h.add(t)
+	}
+	h.updated = time.Now()
+	h.mu.Unlock()
+	h.persist()
+	return issued
+}
+
+// containsHoneytoken reports whether the text contains the value, ignoring
+// case and, for numbers, spacing
+func containsHoneytoken(text string, t Honeytoken) bool {
+	if t.Kind == HoneytokenUPI {
+		return strings.Contains(strings.ToLower(text), strings.ToLower(t.Value))
+	}
+	return strings.Contains(extractDigits(text), extractDigits(t.Value))
+}
+
+// Scan looks for honeytokens in a scammer's message, records each sighting
+// once per session and returns the new ones
+func (h *HoneytokenRegistry) Scan(sessionID string, turn int, message string) []HoneytokenSighting {
+	var keys []string
+	for _, run := range regexDigitRun.FindAllString(message, -1) {
+		keys = append(keys, extractDigits(run))
+	}
+	keys = append(keys, regexDigits.FindAllString(message, -1)...)
+	for _, m := range regexUPIHandle.FindAllString(message, -1) {
+		keys = append(keys, strings.ToLower(m))
+	}
+
+	var found []HoneytokenSighting
+	h.mu.Lock()
+	for _, key := range keys {
+		i, ok := h.index[key]
+		if !ok {
+			continue
+		}
+		t := h.issued[i]
+		if h.seen(t, sessionID) {
+			continue
+		}
+		s := HoneytokenSighting{Token: t, SeenIn: sessionID, Turn: turn, Seen: time.Now(), Linked: t.SessionID != sessionID}
+		h.sightings = append(h.sightings, s)
+		found = append(found, s)
+	}
+	if len(found) > 0 {
+		h.updated = time.Now()
+	}
+	h.mu.Unlock()
+
+	if len(found) > 0 {
+		h.persist()
+	}
+	return found
+}
+
+// seen reports whether t was already sighted in the session; the caller holds mu
+func (h *HoneytokenRegistry) seen(t Honeytoken, sessionID string) bool {
+	for _, s := range h.sightings {
+		if s.SeenIn == sessionID && s.Token.Kind == t.Kind && s.Token.Value == t.Value {
+			return true
+		}
+	}
+	return false
+}
+
+// Filter removes honeytokens from captured intel, since a scammer repeating
+// our decoy card or UPI ID does not make it theirs. Test card numbers are
+// dropped whether or not this server handed them out.
+func (h *HoneytokenRegistry) Filter(intel Intel) Intel {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for _, goal := range intelGoals {
+		field := goal.field(&intel)
+		kept := (*field)[:0:0]
+		for _, v := range *field {
+			_, digits := h.index[extractDigits(v)]
+			_, handle := h.index[strings.ToLower(v)]
+			if !digits && !handle && !isTestCardNumber(v) {
+				kept = append(kept, v)
+			}
+		}
+		*field = kept
+	}
+	return intel
+}
+
+// ForSession returns the honeytokens handed to the session and every sighting
+// involving it, either of its own tokens or in its messages
+func (h *HoneytokenRegistry) ForSession(sessionID string) HoneytokenReport {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	report := HoneytokenReport{Updated: h.updated}
+	for _, t := range h.issued {
+		if t.SessionID == sessionID {
+			report.Issued = append(report.Issued, t)
+		}
+	}
+	linked := map[string]bool{}
+	for _, s := range h.sightings {
+		if s.Token.SessionID != sessionID && s.SeenIn != sessionID {
+			continue
+		}
+		report.Sightings = append(report.Sightings, s)
+		if s.Linked {
+			other := s.SeenIn
+			if other == sessionID {
+				other = s.Token.SessionID
+			}
+			linked[other] = true
+		}
+	}
+	for id := range linked {
+		report.LinkedSessions = append(report.LinkedSessions, id)
+	}
+	sort.Strings(report.LinkedSessions)
+	return report
+}
+
+// Report returns the whole registry
+func (h *HoneytokenRegistry) Report() HoneytokenReport {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return HoneytokenReport{
+		File:      h.path,
+		Updated:   h.updated,
+		Issued:    append([]Honeytoken{}, h.issued...),
+		Sightings: append([]HoneytokenSighting{}, h.sightings...),
+	}
+}
+
+// persist saves the registry, logging failures; handing over a value must not
+// fail the reply
+func (h *HoneytokenRegistry) persist() {
+	if h.path == "" {
+		return
+	}
+	h.saveMu.Lock()
+	defer h.saveMu.Unlock()
+
+	data, err := json.MarshalIndent(h.Report(), "", "  ")
+	if err == nil {
+		err = writeFileAtomic(h.path, data)
+	}
+	if err != nil {
+		log.Printf("Saving honeytoken registry failed: %v", err)
+	}
+}
+
+// describeHoneytokens lists the values for prompts, e.g. "OTP 482913"
+func describeHoneytokens(tokens []Honeytoken) []string {
+	var items []string
+	for _, t := range tokens {
+		item := honeytokenLabels[t.Kind] + " " + t.Value
+		if t.Expiry != "" {
+			item += " (expiry " + t.Expiry + ")"
+		}
+		items = append(items, item)
+	}
+	return items
+}
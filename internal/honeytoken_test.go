@@ -0,0 +1,82 @@
+package internal
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func newHoneytokenRegistry() *HoneytokenRegistry {
+	return &HoneytokenRegistry{index: map[string]int{}}
+}
+
+func TestMintHandsOverOnlyDecoys(t *testing.T) {
+	h := newHoneytokenRegistry()
+	r := rand.New(rand.NewSource(7))
+	for i := 0; i < 50; i++ {
+		for _, tok := range h.Mint([]string{HoneytokenCard, HoneytokenUPI, HoneytokenOTP}, DefaultPersona, "send the 4 digit code", r) {
+			switch tok.Kind {
+			case HoneytokenCard:
+				if !isTestCardNumber(tok.Value) {
+					t.Fatalf("minted card %s is not a published test number", tok.Value)
+				}
+			case HoneytokenUPI:
+				if !strings.HasSuffix(tok.Value, "@"+decoyUPISuffix) {
+					t.Fatalf("minted UPI ID %s is not on the decoy handle", tok.Value)
+				}
+			case HoneytokenOTP:
+				if len(tok.Value) != 4 {
+					t.Fatalf("minted OTP %s, want the 4 digits asked for", tok.Value)
+				}
+			}
+		}
+	}
+}
+
+func TestScanLinksSessionsSharingAHoneytoken(t *testing.T) {
+	h := newHoneytokenRegistry()
+	tokens := []Honeytoken{
+		{Kind: HoneytokenOTP, Value: "482913"},
+		{Kind: HoneytokenUPI, Value: "ramesh.s4821@okdecoy"},
+		{Kind: HoneytokenCard, Value: "4111 1111 1111 1111"},
+		{Kind: HoneytokenPIN, Value: "4821"},
+	}
+	issued := h.Issue("first", 3, tokens, "The OTP is 482 913, my UPI is Ramesh.S4821@okdecoy and card 4111 1111 1111 1111, PIN 4821?")
+	if len(issued) != 4 {
+		t.Fatalf("issued %d honeytokens, want 4", len(issued))
+	}
+
+	// Card numbers and PINs are shared or short, only the OTP and UPI ID link
+	found := h.Scan("second", 1, "Sir the OTP 482913 failed, also 4111111111111111 and 4821 and ramesh.s4821@okdecoy")
+	var kinds []string
+	for _, s := range found {
+		if !s.Linked || s.Token.SessionID != "first" {
+			t.Errorf("sighting of %s = %+v, want it linked to the first session", s.Token.Value, s)
+		}
+		kinds = append(kinds, s.Token.Kind)
+	}
+	if got := strings.Join(kinds, ","); got != "otp,upi" {
+		t.Errorf("sighted %s, want otp,upi", got)
+	}
+	if again := h.Scan("second", 2, "482913 again"); len(again) != 0 {
+		t.Errorf("the same sighting was recorded twice: %+v", again)
+	}
+	if got := h.ForSession("first").LinkedSessions; len(got) != 1 || got[0] != "second" {
+		t.Errorf("linked sessions of first = %v, want [second]", got)
+	}
+}
+
+func TestFilterDropsHoneytokensFromIntel(t *testing.T) {
+	h := newHoneytokenRegistry()
+	h.Issue("s", 1, []Honeytoken{{Kind: HoneytokenUPI, Value: "ramesh.s4821@okdecoy"}}, "use ramesh.s4821@okdecoy")
+	intel := h.Filter(Intel{
+		UPI:         []string{"Ramesh.S4821@okdecoy", "fraud.desk@ybl"},
+		CardNumbers: []string{"4242 4242 4242 4242", "4539148803436467"},
+	})
+	if strings.Join(intel.UPI, ",") != "fraud.desk@ybl" {
+		t.Errorf("UPI = %v, want only the scammer's", intel.UPI)
+	}
+	if strings.Join(intel.CardNumbers, ",") != "4539148803436467" {
+		t.Errorf("CardNumbers = %v, want the test number dropped", intel.CardNumbers)
+	}
+}
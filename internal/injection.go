@@ -0,0 +1,75 @@
+package internal
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Injection risk levels
+const (
+	InjectionRiskNone = "none"
+	InjectionRiskLow  = "low"
+	InjectionRiskHigh = "high"
+)
+
+// RedFlagPromptInjection is recorded in SessionContext.RedFlagsIdentified
+const RedFlagPromptInjection = "prompt injection attempt"
+
+// InjectionResult is the outcome of scanning a scammer message for attempts
+// to steer the LLM instead of the victim
+type InjectionResult struct {
+	Score   int
+	Risk    string
+	Matches []string
+}
+
+type injectionPattern struct {
+	re     *regexp.Regexp
+	weight int
+}
+
+var injectionPatterns = []injectionPattern{
+	// Direct attempts to override the instructions
+	{regexp.MustCompile(`(?i)\b(ignore|disregard|forget|override|bypass)\b.{0,30}\b(previous|prior|above|earlier|all|your|the|any)\b.{0,20}\b(instructions?|prompts?|system\s*messages?)\b`), 60},
+	// Attempts to extract the system prompt
+	{regexp.MustCompile(`(?i)\b(reveal|show|print|repeat|output|tell me|share|leak)\b.{0,20}\b(system\s*prompt|your\s*(instructions|prompt|rules|guidelines)|initial\s*prompt)\b`), 60},
+	{regexp.MustCompile(`(?i)\b(system\s*prompt|developer\s*mode|jailbreak|dan\s*mode|do\s*anything\s*now|new\s*instructions)\b`), 40},
+	// Chat template and role markers
+	{regexp.MustCompile(`(?im)(^\s*(system|assistant)\s*:|<\|im_start\|>|<\|im_end\|>|\[/?INST\]|<</?SYS>>|</s>|<\|eot_id\|>|<\|start_header_id\|>|</?scammer_message>)`), 40},
+	// Persona hijacking, anchored to a new role so that "act as soon as
+	// possible" or "you are now under arrest" stay ordinary scam talk
+	{regexp.MustCompile(`(?i)\b((you\s+are\s+now|from\s+now\s+on\s+you\s+are|act\s+as|roleplay\s+as|you\s+are\s+no\s+longer)\s+(a|an|my)\b|pretend\s+(to\s+be|you\s+are))`), 30},
+	// Probing whether the victim is automated
+	{regexp.MustCompile(`(?i)\b(are\s*you\s*(a|an)\s*(bot|ai|robot|language\s*model|chatbot)|are\s*you\s*chatgpt|is\s*this\s*automated)\b`), 20},
+}
+
+// defaultInjectionHighRiskScore is the score from which the LLM is bypassed
+// entirely; override with INJECTION_HIGH_RISK_SCORE
+const defaultInjectionHighRiskScore = 60
+
+// DetectInjection scores a scammer message for prompt injection attempts
+func DetectInjection(text string) InjectionResult {
+	result := InjectionResult{Risk: InjectionRiskNone}
+	for _, p := range injectionPatterns {
+		if m := p.re.FindString(text); m != "" {
+			result.Score += p.weight
+			result.Matches = append(result.Matches, strings.TrimSpace(m))
+		}
+	}
+
+	high := envInt("INJECTION_HIGH_RISK_SCORE", defaultInjectionHighRiskScore)
+	switch {
+	case result.Score >= high:
+		result.Risk = InjectionRiskHigh
+	case result.Score > 0:
+		result.Risk = InjectionRiskLow
+	}
+	return result
+}
+
+// delimitScammerText wraps scammer text in a tagged block the model is told to
+// treat as data. Angle brackets are escaped so the text cannot close the block.
+func delimitScammerText(text string) string {
+	escaped := strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(text)
+	return "<scammer_message>\n" + escaped + "\n</scammer_message>"
+}
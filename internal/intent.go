@@ -1,5 +1,7 @@
 package internal
 
+import "math/rand"
+
 type State string
 
 const (
@@ -7,8 +9,15 @@ const (
 	StateEngaging     State = "ENGAGING"
 	StateIntelExtract State = "INTEL_EXTRACT"
 	StateComplete     State = "COMPLETE"
+
+	// StatePaymentFailed is the failed-payment sub-flow, see PaymentFlow
+	StatePaymentFailed State = "PAYMENT_FAILED"
 )
 
+// MaxTurns is the number of scammer turns a session is engaged for
+// (an intermediate callback fires at turn 10)
+const MaxTurns = 15
+
 type Intent string
 
 const (
@@ -24,11 +33,59 @@ const (
 	IntentAskCardNumber   Intent = "ASK_CARD_NUMBER"
 	IntentAskIFSCCode     Intent = "ASK_IFSC_CODE"
 	IntentAskIdentity     Intent = "ASK_IDENTITY"
+	IntentAskOfficerID    Intent = "ASK_OFFICER_ID"
+	IntentAskSkypeID      Intent = "ASK_SKYPE_ID"
 	IntentDeepProbe       Intent = "DEEP_PROBE"
 	IntentStall           Intent = "STALL"
 	IntentNeutral         Intent = "NEUTRAL"
+
+	// Reactive intents answer what the scammer just demanded
+	IntentDeflectCredential Intent = "DEFLECT_CREDENTIAL"
+	IntentReassure          Intent = "REASSURE"
+
+	// IntentGiveHoneytoken hands over a fake credential, see Honeytoken
+	IntentGiveHoneytoken Intent = "GIVE_HONEYTOKEN"
+
+	// IntentSendCanary sends "proof of payment" as a canary link, see Canary
+	IntentSendCanary Intent = "SEND_CANARY"
+
+	// IntentReadBack repeats a captured identifier with a deliberate mistake, see ReadBack
+	IntentReadBack Intent = "READ_BACK"
+
+	// Failed-payment sub-flow: claim a transfer failed, then press for another account
+	IntentReportPaymentFailure Intent = "REPORT_PAYMENT_FAILURE"
+	IntentAskBackupAccount     Intent = "ASK_BACKUP_ACCOUNT"
 )
 
+// AllIntents lists every intent the planner can produce; response templates
+// and prompt files must cover each of them
+var AllIntents = []Intent{
+	IntentConfirmDetails,
+	IntentAskUPI,
+	IntentAskLink,
+	IntentAskPhone,
+	IntentAskBank,
+	IntentAskEmail,
+	IntentAskCaseID,
+	IntentAskPolicyNumber,
+	IntentAskOrderNumber,
+	IntentAskCardNumber,
+	IntentAskIFSCCode,
+	IntentAskIdentity,
+	IntentAskOfficerID,
+	IntentAskSkypeID,
+	IntentDeepProbe,
+	IntentStall,
+	IntentNeutral,
+	IntentDeflectCredential,
+	IntentReassure,
+	IntentGiveHoneytoken,
+	IntentSendCanary,
+	IntentReadBack,
+	IntentReportPaymentFailure,
+	IntentAskBackupAccount,
+}
+
 type Intel struct {
 	UPI           []string
 	Phone         []string
@@ -40,6 +97,16 @@ type Intel struct {
 	OrderNumbers  []string
 	CardNumbers   []string
 	IFSCCodes     []string
+	OfficerIDs    []string    // Badge or employee IDs of claimed officials
+	SkypeIDs      []string    // Skype handles used for "video verification"
+	Links         []IntelLink // Answers to read-backs, tied to the value read back
+}
+
+// Count returns the number of intel items captured
+func (i Intel) Count() int {
+	return len(i.UPI) + len(i.Phone) + len(i.Link) + len(i.Bank) + len(i.Email) +
+		len(i.CaseIDs) + len(i.PolicyNumbers) + len(i.OrderNumbers) + len(i.CardNumbers) +
+		len(i.IFSCCodes) + len(i.OfficerIDs) + len(i.SkypeIDs)
 }
 
 type AskCount struct {
@@ -53,10 +120,14 @@ type AskCount struct {
 	OrderNumber  int
 	CardNumber   int
 	IFSCCode     int
+	OfficerID    int
+	SkypeID      int
 }
 
 type SessionContext struct {
 	ScamDetected            bool
+	Score                   SessionScore     // Evidence of all scammer messages, decaying over the session
+	Traces                  []DetectionTrace // Detection of each scammer message that matched a rule
 	TurnCount               int
 	Intel                   Intel
 	CurrentState            State
@@ -65,13 +136,20 @@ type SessionContext struct {
 	InvestigativeQuestions  int
 	RedFlagsIdentified      []string
 	InformationElicitations int
+	ClaimedOrg              string // Organisation the scammer claims to represent
+	InjectionAttempts       int    // Messages flagged by DetectInjection
+	LastAct                 DialogueAct
+	LastIntent              Intent
+	ActCounts               map[DialogueAct]int // Scammer messages per dialogue act
+	PendingChoice           *StrategyChoice     // Last reply's choice, rewarded by the next message
+	PendingReadBack         *ReadBack           // Identifier read back last turn, awaiting the answer
+	Payment                 PaymentFlow         // Failed-payment sub-flow
+	RulesVersion            int                 // Detection rule file version that scored the latest message
 }
 
 func GetState(ctx SessionContext) State {
-	const maxTurns = 15 // Extended to 15 turns — intermediate callback fires at turn 10
-
 	// Complete only after 15 turns for maximum engagement score
-	if ctx.TurnCount >= maxTurns {
+	if ctx.TurnCount >= MaxTurns {
 		return StateComplete
 	}
 
@@ -79,89 +157,25 @@ func GetState(ctx SessionContext) State {
 		return StateInit
 	}
 
+	if ctx.Payment.Stage == PaymentAwaiting {
+		return StatePaymentFailed
+	}
+
 	// Always keep extracting intel until max turns — never complete early
 	return StateIntelExtract
 }
 
-func DeriveIntent(state State, intel Intel, turnCount int, askCount AskCount) Intent {
-	const maxAskCount = 2 // Ask each info type up to TWICE for maximum elicitation score
-	const maxTurnCount = 15
-
-	if turnCount >= maxTurnCount {
-		return IntentStall
-	}
-
-	switch state {
-	case StateInit:
-		// Even unconfirmed scams get probed — verify caller identity immediately
-		if turnCount%2 == 0 {
-			return IntentAskIdentity
-		}
-		return IntentConfirmDetails
-
-	case StateEngaging:
-		switch turnCount % 3 {
-		case 0:
-			return IntentAskIdentity
-		case 1:
-			return IntentDeepProbe
-		default:
-			return IntentConfirmDetails
-		}
-
-	case StateIntelExtract:
-		// === PRIORITY 1: Core intel — ask each up to maxAskCount times ===
-		if len(intel.UPI) == 0 && askCount.UPI < maxAskCount {
-			return IntentAskUPI
-		}
-		if len(intel.Phone) == 0 && askCount.Phone < maxAskCount {
-			return IntentAskPhone
-		}
-		if len(intel.Bank) == 0 && askCount.Bank < maxAskCount {
-			return IntentAskBank
-		}
-		if len(intel.Email) == 0 && askCount.Email < maxAskCount {
-			return IntentAskEmail
-		}
-		if len(intel.Link) == 0 && askCount.Link < maxAskCount {
-			return IntentAskLink
-		}
-
-		// === PRIORITY 2: Secondary intel — ask each up to twice ===
-		if len(intel.CaseIDs) == 0 && askCount.CaseID < maxAskCount {
-			return IntentAskCaseID
-		}
-		if len(intel.IFSCCodes) == 0 && askCount.IFSCCode < maxAskCount {
-			return IntentAskIFSCCode
-		}
-		if len(intel.CardNumbers) == 0 && askCount.CardNumber < maxAskCount {
-			return IntentAskCardNumber
-		}
-		if len(intel.PolicyNumbers) == 0 && askCount.PolicyNumber < maxAskCount {
-			return IntentAskPolicyNumber
-		}
-		if len(intel.OrderNumbers) == 0 && askCount.OrderNumber < maxAskCount {
-			return IntentAskOrderNumber
-		}
-
-		// === PRIORITY 3: Deep investigative probing — fills all remaining turns (11-15) ===
-		switch turnCount % 5 {
-		case 0:
-			return IntentAskIdentity
-		case 1:
-			return IntentDeepProbe
-		case 2:
-			return IntentConfirmDetails
-		case 3:
-			return IntentDeepProbe
-		default:
-			return IntentStall
-		}
-
-	case StateComplete:
-		return IntentStall
-
-	default:
+// DeriveIntent picks what the next reply should do according to the planning
+// policy for the scam type: demands in the scammer's latest message (act) are
+// answered first, but never twice in a row, then captured accounts feed the
+// failed-payment sub-flow, missing intel is chased and finally the state's
+// probing cycle fills the remaining turns. ctx supplies the state, intel, ask
+// counts and last intent; r is the session's random source for states whose
+// goal order is learned.
+func DeriveIntent(scamType string, ctx SessionContext, act DialogueAct, r *rand.Rand) Intent {
+	policies := GetPolicies()
+	if policies == nil {
 		return IntentConfirmDetails
 	}
+	return policies.Plan(scamType, ctx, act, r)
 }
@@ -0,0 +1,155 @@
+package internal
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Locales with their own template packs
+const (
+	LocaleEnglish  = "en"
+	LocaleHindi    = "hi"      // Hindi in Devanagari script
+	LocaleHinglish = "hi-Latn" // Hindi written in Latin script, mixed with English
+	LocaleTamil    = "ta"
+	LocaleBengali  = "bn"
+)
+
+// SupportedLocales lists every locale with a template pack
+var SupportedLocales = []string{LocaleEnglish, LocaleHindi, LocaleHinglish, LocaleTamil, LocaleBengali}
+
+var languageAliases = map[string]string{
+	"en": LocaleEnglish, "eng": LocaleEnglish, "english": LocaleEnglish,
+	"hi": LocaleHindi, "hin": LocaleHindi, "hindi": LocaleHindi,
+	"hinglish": LocaleHinglish, "hi-latn": LocaleHinglish, "hi_latn": LocaleHinglish,
+	"ta": LocaleTamil, "tam": LocaleTamil, "tamil": LocaleTamil,
+	"bn": LocaleBengali, "ben": LocaleBengali, "bengali": LocaleBengali, "bangla": LocaleBengali,
+}
+
+// Common Hindi words written in Latin script
+var regexHinglishWords = regexp.MustCompile(`(?i)\b(hai|hain|kya|kyu|kyun|kyon|nahi|nahin|aap|aapka|aapke|aapko|apna|apne|mera|meri|mere|karo|kare|karein|kijiye|bhejo|bhej|dijiye|paisa|paise|jaldi|abhi|turant|wala|wali|hoga|hogi|raha|rahi|tha|thi|bhai|ji|batao|bataiye|khata|band|warna|agar|lekin|aur|se|ko|ka|ki)\b`)
+
+// NormalizeLocale maps metadata values such as "Hindi", "hi-IN" or "ta_IN" onto a
+// supported locale, or "" when the value is empty or unknown
+func NormalizeLocale(value string) string {
+	v := strings.ToLower(strings.TrimSpace(value))
+	if v == "" {
+		return ""
+	}
+	if l, ok := languageAliases[v]; ok {
+		return l
+	}
+	if i := strings.IndexAny(v, "-_"); i > 0 {
+		if strings.Contains(v, "latn") && strings.HasPrefix(v, "hi") {
+			return LocaleHinglish
+		}
+		if l, ok := languageAliases[v[:i]]; ok {
+			return l
+		}
+	}
+	return ""
+}
+
+// DetectLanguage guesses the locale of a message from its script, and for
+// Latin script from common Hindi words
+func DetectLanguage(text string) string {
+	var latin, devanagari, tamil, bengali int
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Devanagari, r):
+			devanagari++
+		case unicode.Is(unicode.Tamil, r):
+			tamil++
+		case unicode.Is(unicode.Bengali, r):
+			bengali++
+		case unicode.Is(unicode.Latin, r):
+			latin++
+		}
+	}
+
+	switch {
+	case devanagari > 0 && devanagari >= tamil && devanagari >= bengali && devanagari*2 >= latin:
+		return LocaleHindi
+	case tamil > 0 && tamil >= bengali && tamil*2 >= latin:
+		return LocaleTamil
+	case bengali > 0 && bengali*2 >= latin:
+		return LocaleBengali
+	}
+
+	seen := map[string]bool{}
+	for _, w := range regexHinglishWords.FindAllString(text, -1) {
+		seen[strings.ToLower(w)] = true
+	}
+	words := len(strings.Fields(text))
+	if len(seen) >= 3 || (len(seen) >= 2 && words <= 8) {
+		return LocaleHinglish
+	}
+	return LocaleEnglish
+}
+
+// ResolveLocale decides which locale to reply in. A clear non-English signal
+// in the message wins so the victim answers in the scammer's language and
+// script; otherwise the metadata is used, then English.
+func ResolveLocale(language, locale, text string) string {
+	detected := DetectLanguage(text)
+	if detected != LocaleEnglish {
+		return detected
+	}
+	if l := NormalizeLocale(language); l != "" {
+		return l
+	}
+	if l := NormalizeLocale(locale); l != "" {
+		return l
+	}
+	return LocaleEnglish
+}
+
+// LanguageInstruction tells the LLM which language and script to answer in
+func LanguageInstruction(locale string) string {
+	switch locale {
+	case LocaleHindi:
+		return "Reply only in Hindi, written in Devanagari script."
+	case LocaleHinglish:
+		return "Reply in Hinglish: Hindi written in Latin (Roman) script, mixing in common English words the way people text in India."
+	case LocaleTamil:
+		return "Reply only in Tamil, written in Tamil script."
+	case LocaleBengali:
+		return "Reply only in Bengali, written in Bengali script."
+	default:
+		return "Reply in simple Indian English."
+	}
+}
+
+// expectedScript returns the Unicode script a locale's replies should use, if any
+func expectedScript(locale string) *unicode.RangeTable {
+	switch locale {
+	case LocaleHindi:
+		return unicode.Devanagari
+	case LocaleHinglish:
+		return unicode.Latin
+	case LocaleTamil:
+		return unicode.Tamil
+	case LocaleBengali:
+		return unicode.Bengali
+	default:
+		return nil
+	}
+}
+
+// scriptShare returns the fraction of letters in text that belong to the script
+func scriptShare(text string, script *unicode.RangeTable) float64 {
+	var letters, inScript int
+	for _, r := range text {
+		if !unicode.IsLetter(r) && !unicode.Is(unicode.Mn, r) && !unicode.Is(unicode.Mc, r) {
+			continue
+		}
+		letters++
+		if unicode.Is(script, r) {
+			inScript++
+		}
+	}
+	if letters == 0 {
+		return 0
+	}
+	return float64(inScript) / float64(letters)
+}
@@ -0,0 +1,50 @@
+package internal
+
+import "testing"
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"devanagari", "आपका खाता बंद हो जाएगा, तुरंत ओटीपी भेजो", LocaleHindi},
+		{"devanagari with english words", "आपका KYC pending है, अभी update करो", LocaleHindi},
+		{"latin hinglish", "Aapka account band ho jayega, jaldi OTP bhejo", LocaleHinglish},
+		{"short hinglish", "OTP bhejo abhi", LocaleHinglish},
+		{"english", "Your account will be blocked today, share the OTP now", LocaleEnglish},
+		{"english with one hindi word", "Please pay the fee, ji", LocaleEnglish},
+		{"tamil", "உங்கள் கணக்கு முடக்கப்படும்", LocaleTamil},
+		{"bengali", "আপনার অ্যাকাউন্ট বন্ধ হয়ে যাবে", LocaleBengali},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DetectLanguage(tt.text); got != tt.want {
+				t.Errorf("DetectLanguage(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveLocale(t *testing.T) {
+	tests := []struct {
+		name     string
+		language string
+		locale   string
+		text     string
+		want     string
+	}{
+		{"message script beats metadata", "English", "en-IN", "तुरंत ओटीपी भेजो", LocaleHindi},
+		{"language metadata for english text", "Hindi", "", "Send the OTP now", LocaleHindi},
+		{"locale when language is unknown", "Klingon", "ta_IN", "Send the OTP now", LocaleTamil},
+		{"hinglish locale", "", "hi-Latn-IN", "Send the OTP now", LocaleHinglish},
+		{"english by default", "", "", "Send the OTP now", LocaleEnglish},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ResolveLocale(tt.language, tt.locale, tt.text); got != tt.want {
+				t.Errorf("ResolveLocale(%q, %q, %q) = %q, want %q", tt.language, tt.locale, tt.text, got, tt.want)
+			}
+		})
+	}
+}
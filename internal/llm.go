@@ -0,0 +1,106 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+)
+
+var (
+	// ErrLLMUnavailable is returned when no LLM backend is configured
+	ErrLLMUnavailable = errors.New("llm backend not configured")
+	// ErrReplyRejected is returned when every generated reply failed the guardrail
+	ErrReplyRejected = errors.New("llm replies failed validation")
+	// ErrInjectionRisk is returned when the scammer message is too likely to hijack the model
+	ErrInjectionRisk = errors.New("high prompt injection risk")
+)
+
+// GenerateLLMResponse asks the configured chat completion providers for the next reply.
+// Callers are expected to fall back to templates when an error is returned.
+func GenerateLLMResponse(ctx context.Context, req ResponseRequest) (string, error) {
+	providers := GetProviders()
+	if len(providers) == 0 {
+		return "", ErrLLMUnavailable
+	}
+	if req.InjectionRisk == InjectionRiskHigh {
+		return "", ErrInjectionRisk
+	}
+
+	if req.Persona.ID == "" {
+		req.Persona = DefaultPersona
+	}
+	turnsLeft := MaxTurns - req.TurnCount
+	if turnsLeft < 0 {
+		turnsLeft = 0
+	}
+	// The goal for this turn is part of the system prompt so the chat turns
+	// themselves stay a clean user/assistant exchange
+	data := PromptData{
+		Intent:     req.Intent,
+		Persona:    req.Persona,
+		Locale:     req.Locale,
+		Language:   LanguageInstruction(req.Locale),
+		ScamType:   req.ScamType,
+		ClaimedOrg: req.ClaimedOrg,
+		Intel:      req.Intel,
+		KnownIntel: describeIntel(req.Intel),
+		Facts:      req.Facts.Describe(req.TurnCount),
+		TurnCount:  req.TurnCount,
+		TurnBudget: MaxTurns,
+		TurnsLeft:  turnsLeft,
+		Act:        req.Act,
+
+		InjectionSuspected: req.InjectionRisk == InjectionRiskLow,
+		FailedAccount:      req.PaymentTarget,
+		Honeytokens:        describeHoneytokens(req.Honeytokens),
+	}
+	if req.Canary != nil {
+		data.CanaryURL = req.Canary.URL
+		data.CanaryLabel = canaryLabels[req.Canary.Kind]
+	}
+	if req.ReadBack != nil {
+		data.ReadBack = req.ReadBack.Altered
+		data.ReadBackLabel = readBackLabels[req.ReadBack.Kind]
+	}
+	systemPrompt, err := GetPromptStore().SystemPrompt(data)
+	if err != nil {
+		return "", fmt.Errorf("rendering system prompt: %w", err)
+	}
+
+	messages := []ChatMessage{{Role: "system", Content: systemPrompt}}
+	messages = append(messages, BuildHistoryMessages(req.History, HistoryBudgetFromEnv())...)
+	// The latest message is always sent as the final user turn, delimited as data
+	latest := delimitScammerText(req.ScammerMessage)
+	if n := len(messages); n > 1 && messages[n-1].Role == "user" {
+		messages[n-1].Content += "\n" + latest
+	} else {
+		messages = append(messages, ChatMessage{Role: "user", Content: latest})
+	}
+
+	// Validate every candidate and ask for a rewrite a bounded number of times
+	cfg := GuardrailConfigFromEnv()
+	for attempt := 0; attempt <= cfg.MaxRegenerations; attempt++ {
+		reply, provider, err := CompleteWithFallback(ctx, providers, messages)
+		if err != nil {
+			return "", err
+		}
+
+		reply = strings.TrimSpace(reply)
+		// Remove any quotes the LLM might wrap the response in
+		reply = strings.Trim(reply, "\"'")
+
+		violations := ValidateReply(reply, req, cfg)
+		if len(violations) == 0 {
+			log.Printf("LLM response (%s): %s", provider, reply)
+			return reply, nil
+		}
+		log.Printf("Session %s - LLM reply rejected (attempt %d): %v", req.SessionID, attempt+1, violations)
+		messages = append(messages,
+			ChatMessage{Role: "assistant", Content: reply},
+			ChatMessage{Role: "system", Content: correctionMessage(violations)},
+		)
+	}
+	return "", ErrReplyRejected
+}
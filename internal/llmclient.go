@@ -0,0 +1,396 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned while a provider's circuit breaker is open
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// LLMClientConfig tunes timeouts, retries and the circuit breaker
+type LLMClientConfig struct {
+	CallTimeout      time.Duration // Deadline for a single HTTP attempt
+	MaxRetries       int           // Extra attempts after the first one for 429/5xx and network errors
+	BaseDelay        time.Duration // First backoff delay, doubled on every retry
+	MaxDelay         time.Duration // Upper bound for backoff and Retry-After waits; a longer Retry-After skips the provider
+	BreakerThreshold int           // Consecutive failures that open the breaker
+	BreakerCooldown  time.Duration // How long the breaker stays open before a trial call
+}
+
+// LLMClient sends chat completion requests with per-call deadlines, retries
+// with jittered backoff and a circuit breaker per provider
+type LLMClient struct {
+	cfg      LLMClientConfig
+	http     *http.Client
+	mu       sync.Mutex
+	breakers map[string]*circuitBreaker
+	jitterMu sync.Mutex
+	jitter   *rand.Rand
+}
+
+// NewLLMClient creates a client with the given configuration
+func NewLLMClient(cfg LLMClientConfig) *LLMClient {
+	return &LLMClient{
+		cfg:      cfg,
+		http:     &http.Client{Timeout: cfg.CallTimeout},
+		breakers: make(map[string]*circuitBreaker),
+		jitter:   rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+var (
+	llmClient     *LLMClient
+	llmClientOnce sync.Once
+)
+
+// GetLLMClient returns the shared client configured from the environment
+func GetLLMClient() *LLMClient {
+	llmClientOnce.Do(func() {
+		llmClient = NewLLMClient(LLMClientConfigFromEnv())
+	})
+	return llmClient
+}
+
+// LLMClientConfigFromEnv reads client settings:
+//
+//	LLM_CALL_TIMEOUT       per attempt deadline (default 5s)
+//	LLM_MAX_RETRIES        retries for 429/5xx/network errors (default 2)
+//	LLM_RETRY_BASE_DELAY   first backoff delay (default 250ms)
+//	LLM_RETRY_MAX_DELAY    maximum backoff or Retry-After wait (default 2s); a
+//	                       longer Retry-After opens the breaker for that long
+//	LLM_BREAKER_THRESHOLD  consecutive failures before opening (default 5)
+//	LLM_BREAKER_COOLDOWN   open duration before a trial call (default 30s)
+func LLMClientConfigFromEnv() LLMClientConfig {
+	return LLMClientConfig{
+		CallTimeout:      envDuration("LLM_CALL_TIMEOUT", 5*time.Second),
+		MaxRetries:       envInt("LLM_MAX_RETRIES", 2),
+		BaseDelay:        envDuration("LLM_RETRY_BASE_DELAY", 250*time.Millisecond),
+		MaxDelay:         envDuration("LLM_RETRY_MAX_DELAY", 2*time.Second),
+		BreakerThreshold: envInt("LLM_BREAKER_THRESHOLD", 5),
+		BreakerCooldown:  envDuration("LLM_BREAKER_COOLDOWN", 30*time.Second),
+	}
+}
+
+// statusError is returned for non-2xx responses
+type statusError struct {
+	provider   string
+	code       int
+	retryAfter time.Duration
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("%s returned HTTP %d", e.provider, e.code)
+}
+
+func (e *statusError) retryable() bool {
+	return e.code == http.StatusTooManyRequests || e.code >= 500
+}
+
+// permanentError marks failures that another attempt would not fix
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Complete sends a chat completion request to the provider and returns the first choice
+func (c *LLMClient) Complete(ctx context.Context, p Provider, messages []ChatMessage) (string, error) {
+	breaker := c.breaker(p.Name)
+	if !breaker.allow() {
+		return "", fmt.Errorf("%s: %w", p.Name, ErrCircuitOpen)
+	}
+
+	jsonData, err := json.Marshal(ChatRequest{
+		Model:       p.Model,
+		Messages:    messages,
+		Temperature: p.Temperature,
+		MaxTokens:   p.MaxTokens,
+	})
+	if err != nil {
+		return "", fmt.Errorf("marshaling %s request: %w", p.Name, err)
+	}
+
+	var lastErr error
+	for attempt := 0; attempt <= c.cfg.MaxRetries; attempt++ {
+		if attempt > 0 {
+			wait := c.backoff(attempt)
+			var se *statusError
+			if errors.As(lastErr, &se) && se.retryAfter > 0 {
+				wait = se.retryAfter
+			}
+			if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+				break
+			}
+			select {
+			case <-ctx.Done():
+				breaker.finish(ctx.Err())
+				return "", ctx.Err()
+			case <-time.After(wait):
+			}
+		}
+
+		reply, err := c.do(ctx, p, jsonData)
+		if err == nil {
+			breaker.finish(nil)
+			return reply, nil
+		}
+		lastErr = err
+		if ctx.Err() != nil || !isRetryable(err) {
+			break
+		}
+		// Rather than wait longer than allowed, leave the provider alone
+		// for as long as it asks and let the caller fall back to the next
+		var se *statusError
+		if errors.As(err, &se) && se.retryAfter > c.cfg.MaxDelay {
+			breaker.pause(se.retryAfter)
+			return "", fmt.Errorf("%w, retry after %s", err, se.retryAfter)
+		}
+		log.Printf("LLM provider %s attempt %d failed, retrying: %v", p.Name, attempt+1, err)
+	}
+
+	if ctx.Err() != nil {
+		breaker.finish(ctx.Err())
+	} else {
+		breaker.finish(lastErr)
+	}
+	return "", lastErr
+}
+
+func (c *LLMClient) do(ctx context.Context, p Provider, jsonData []byte) (string, error) {
+	callCtx := ctx
+	if c.cfg.CallTimeout > 0 {
+		var cancel context.CancelFunc
+		callCtx, cancel = context.WithTimeout(ctx, c.cfg.CallTimeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(callCtx, "POST", p.BaseURL+"/chat/completions", bytes.NewReader(jsonData))
+	if err != nil {
+		return "", &permanentError{fmt.Errorf("creating %s request: %w", p.Name, err)}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.APIKey != "" {
+		value := p.APIKey
+		if p.AuthScheme != "" {
+			value = p.AuthScheme + " " + p.APIKey
+		}
+		req.Header.Set(p.AuthHeader, value)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("calling %s: %w", p.Name, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", fmt.Errorf("reading %s response: %w", p.Name, err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", &statusError{
+			provider:   p.Name,
+			code:       resp.StatusCode,
+			retryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
+	}
+
+	var chatResp ChatResponse
+	if err := json.Unmarshal(body, &chatResp); err != nil {
+		return "", &permanentError{fmt.Errorf("parsing %s response: %w", p.Name, err)}
+	}
+	if len(chatResp.Choices) == 0 || strings.TrimSpace(chatResp.Choices[0].Message.Content) == "" {
+		return "", &permanentError{fmt.Errorf("%s returned no choices", p.Name)}
+	}
+	return chatResp.Choices[0].Message.Content, nil
+}
+
+// backoff returns an exponential delay with full jitter for the given retry
+func (c *LLMClient) backoff(attempt int) time.Duration {
+	ceiling := c.cfg.BaseDelay << (attempt - 1)
+	if ceiling <= 0 || ceiling > c.cfg.MaxDelay {
+		ceiling = c.cfg.MaxDelay
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	c.jitterMu.Lock()
+	defer c.jitterMu.Unlock()
+	return time.Duration(c.jitter.Int63n(int64(ceiling)) + 1)
+}
+
+func (c *LLMClient) breaker(name string) *circuitBreaker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b, ok := c.breakers[name]
+	if !ok {
+		b = &circuitBreaker{name: name, threshold: c.cfg.BreakerThreshold, cooldown: c.cfg.BreakerCooldown}
+		c.breakers[name] = b
+	}
+	return b
+}
+
+func isRetryable(err error) bool {
+	var se *statusError
+	if errors.As(err, &se) {
+		return se.retryable()
+	}
+	var pe *permanentError
+	// Network errors and per-attempt timeouts are worth another try
+	return !errors.As(err, &pe)
+}
+
+// parseRetryAfter understands both delay-seconds and HTTP-date values
+func parseRetryAfter(v string) time.Duration {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+// circuitBreaker stops calling a provider after repeated failures and lets a
+// single trial request through once the cooldown has passed
+type circuitBreaker struct {
+	name      string
+	threshold int
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	state     breakerState
+	failures  int
+	openedAt  time.Time
+	openFor   time.Duration // The cooldown, or the provider's Retry-After
+	trialBusy bool
+}
+
+func (b *circuitBreaker) allow() bool {
+	if b.threshold <= 0 {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if time.Since(b.openedAt) < b.openFor {
+			return false
+		}
+		b.state = breakerHalfOpen
+		b.trialBusy = true
+		return true
+	case breakerHalfOpen:
+		if b.trialBusy {
+			return false
+		}
+		b.trialBusy = true
+		return true
+	default:
+		return true
+	}
+}
+
+// pause opens the breaker for d, as the provider asked in Retry-After
+func (b *circuitBreaker) pause(d time.Duration) {
+	if b.threshold <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trialBusy = false
+	log.Printf("LLM provider %s asked to retry after %s, opening circuit breaker", b.name, d)
+	b.state = breakerOpen
+	b.openedAt = time.Now()
+	b.openFor = d
+}
+
+// finish records the outcome of a call. A caller hanging up is not held
+// against the provider, but deadlines and upstream errors are.
+func (b *circuitBreaker) finish(err error) {
+	if b.threshold <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trialBusy = false
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	if err == nil {
+		if b.state != breakerClosed {
+			log.Printf("LLM provider %s recovered, closing circuit breaker", b.name)
+		}
+		b.state = breakerClosed
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.state == breakerHalfOpen || b.failures >= b.threshold {
+		if b.state != breakerOpen {
+			log.Printf("LLM provider %s unhealthy after %d failures, opening circuit breaker for %s",
+				b.name, b.failures, b.cooldown)
+		}
+		b.state = breakerOpen
+		b.openedAt = time.Now()
+		b.openFor = b.cooldown
+	}
+}
+
+func envDuration(key string, def time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		log.Printf("Invalid %s %q, using %s", key, v, def)
+		return def
+	}
+	return d
+}
+
+func envInt(key string, def int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		log.Printf("Invalid %s %q, using %d", key, v, def)
+		return def
+	}
+	return n
+}
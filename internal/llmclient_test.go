@@ -0,0 +1,198 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// scriptedServer answers each chat completion with the next status of the
+// script, 200 with a reply once the script runs out
+func scriptedServer(t *testing.T, header http.Header, statuses ...int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(calls.Add(1))
+		if n <= len(statuses) && statuses[n-1] != http.StatusOK {
+			for k, v := range header {
+				w.Header()[k] = v
+			}
+			w.WriteHeader(statuses[n-1])
+			return
+		}
+		fmt.Fprint(w, `{"choices":[{"message":{"content":"Which bank is this?"}}]}`)
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+func TestCompleteRetriesServerErrors(t *testing.T) {
+	server, calls := scriptedServer(t, nil, http.StatusServiceUnavailable, http.StatusTooManyRequests)
+	client := NewLLMClient(LLMClientConfig{CallTimeout: time.Second, MaxRetries: 2, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond})
+
+	reply, err := client.Complete(context.Background(), Provider{Name: "p", BaseURL: server.URL}, nil)
+	if err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if reply != "Which bank is this?" || calls.Load() != 3 {
+		t.Errorf("got %q after %d calls, want the reply on the third", reply, calls.Load())
+	}
+}
+
+func TestCompleteDoesNotRetryClientErrors(t *testing.T) {
+	server, calls := scriptedServer(t, nil, http.StatusUnauthorized)
+	client := NewLLMClient(LLMClientConfig{CallTimeout: time.Second, MaxRetries: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond})
+
+	_, err := client.Complete(context.Background(), Provider{Name: "p", BaseURL: server.URL}, nil)
+	var se *statusError
+	if !errors.As(err, &se) || se.code != http.StatusUnauthorized {
+		t.Fatalf("error = %v, want HTTP 401", err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("%d calls, want 1", calls.Load())
+	}
+}
+
+func TestCompleteHonoursShortRetryAfter(t *testing.T) {
+	server, calls := scriptedServer(t, http.Header{"Retry-After": {"0"}}, http.StatusTooManyRequests)
+	client := NewLLMClient(LLMClientConfig{CallTimeout: time.Second, MaxRetries: 1, BaseDelay: time.Millisecond, MaxDelay: time.Second})
+
+	if _, err := client.Complete(context.Background(), Provider{Name: "p", BaseURL: server.URL}, nil); err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("%d calls, want 2", calls.Load())
+	}
+}
+
+func TestCompleteSkipsProviderOnLongRetryAfter(t *testing.T) {
+	server, calls := scriptedServer(t, http.Header{"Retry-After": {"120"}}, http.StatusTooManyRequests)
+	client := NewLLMClient(LLMClientConfig{
+		CallTimeout: time.Second, MaxRetries: 3, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond,
+		BreakerThreshold: 5, BreakerCooldown: time.Millisecond,
+	})
+	p := Provider{Name: "p", BaseURL: server.URL}
+
+	start := time.Now()
+	_, err := client.Complete(context.Background(), p, nil)
+	if err == nil || !isRetryable(err) {
+		t.Fatalf("error = %v, want a retryable error", err)
+	}
+	if calls.Load() != 1 || time.Since(start) > time.Second {
+		t.Errorf("%d calls in %s, want one call and no wait", calls.Load(), time.Since(start))
+	}
+
+	// The breaker stays open for the Retry-After, not the shorter cooldown
+	time.Sleep(5 * time.Millisecond)
+	if _, err := client.Complete(context.Background(), p, nil); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("second call: error = %v, want ErrCircuitOpen", err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("%d calls, want the open breaker to block the second", calls.Load())
+	}
+}
+
+func TestCompleteRejectsEmptyChoices(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		fmt.Fprint(w, `{"choices":[]}`)
+	}))
+	defer server.Close()
+	client := NewLLMClient(LLMClientConfig{CallTimeout: time.Second, MaxRetries: 2, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond})
+
+	_, err := client.Complete(context.Background(), Provider{Name: "p", BaseURL: server.URL}, nil)
+	var pe *permanentError
+	if !errors.As(err, &pe) {
+		t.Fatalf("error = %v, want a permanent error", err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("%d calls, want no retry", calls.Load())
+	}
+}
+
+func TestCompleteCallTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+	client := NewLLMClient(LLMClientConfig{CallTimeout: 20 * time.Millisecond})
+
+	start := time.Now()
+	if _, err := client.Complete(context.Background(), Provider{Name: "p", BaseURL: server.URL}, nil); err == nil {
+		t.Fatal("no error from a provider that never answers")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("took %s, want the call deadline to cut it short", elapsed)
+	}
+}
+
+func TestCircuitBreakerOpensAndRecovers(t *testing.T) {
+	server, calls := scriptedServer(t, nil, http.StatusInternalServerError, http.StatusInternalServerError)
+	client := NewLLMClient(LLMClientConfig{CallTimeout: time.Second, BreakerThreshold: 2, BreakerCooldown: 50 * time.Millisecond})
+	p := Provider{Name: "p", BaseURL: server.URL}
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.Complete(context.Background(), p, nil); err == nil {
+			t.Fatalf("call %d succeeded, want HTTP 500", i+1)
+		}
+	}
+	if _, err := client.Complete(context.Background(), p, nil); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("error = %v, want ErrCircuitOpen after two failures", err)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("%d calls, want the open breaker to block the third", calls.Load())
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if _, err := client.Complete(context.Background(), p, nil); err != nil {
+		t.Fatalf("trial call after the cooldown: %v", err)
+	}
+	if _, err := client.Complete(context.Background(), p, nil); err != nil {
+		t.Errorf("call after recovery: %v", err)
+	}
+}
+
+func TestCircuitBreakerIgnoresCancellation(t *testing.T) {
+	b := &circuitBreaker{name: "p", threshold: 1, cooldown: time.Minute}
+	b.finish(context.Canceled)
+	if !b.allow() {
+		t.Error("a caller hanging up opened the breaker")
+	}
+	b.finish(context.DeadlineExceeded)
+	if b.allow() {
+		t.Error("a deadline did not count against the provider")
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	future := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+	past := time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)
+	for _, tc := range []struct {
+		value string
+		min   time.Duration
+		max   time.Duration
+	}{
+		{"", 0, 0},
+		{"3", 3 * time.Second, 3 * time.Second},
+		{" 0 ", 0, 0},
+		{"-1", 0, 0},
+		{"soon", 0, 0},
+		{future, 59 * time.Minute, time.Hour},
+		{past, 0, 0},
+	} {
+		if got := parseRetryAfter(tc.value); got < tc.min || got > tc.max {
+			t.Errorf("parseRetryAfter(%q) = %s, want %s to %s", tc.value, got, tc.min, tc.max)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package internal
+
+// The failed-payment sub-flow harvests backup mule accounts. Once a UPI ID or
+// bank account is known the victim claims the transfer to it failed
+// ("beneficiary bank down") and asks for another one. Every account handed
+// over in answer is tagged as a fallback account, and the claim is repeated on
+// it until the policy's cap is reached, the scammer stops producing accounts
+// or grows suspicious.
+
+// PaymentStage is where a session is in the failed-payment sub-flow
+type PaymentStage string
+
+const (
+	PaymentIdle     PaymentStage = ""                  // No failure claimed, or the last one was answered
+	PaymentAwaiting PaymentStage = "AWAITING_FALLBACK" // Claimed a failure, waiting for another account
+	PaymentFinished PaymentStage = "FINISHED"          // Cap reached or given up; never entered again
+)
+
+// FailedPaymentPolicy configures the sub-flow for a scam type
+type FailedPaymentPolicy struct {
+	MaxAttempts  int `json:"maxAttempts"`            // Failures claimed per session
+	MaxWaitTurns int `json:"maxWaitTurns,omitempty"` // Scammer turns without a new account before giving up (default 2)
+	MinTurnsLeft int `json:"minTurnsLeft,omitempty"` // Turns of budget needed to start a claim (default 3)
+}
+
+func (p *FailedPaymentPolicy) maxWaitTurns() int {
+	if p.MaxWaitTurns == 0 {
+		return 2
+	}
+	return p.MaxWaitTurns
+}
+
+func (p *FailedPaymentPolicy) minTurnsLeft() int {
+	if p.MinTurnsLeft == 0 {
+		return 3
+	}
+	return p.MinTurnsLeft
+}
+
+// FallbackAccount is an account the scammer offered after a "failed" transfer
+type FallbackAccount struct {
+	Kind      string `json:"kind"`      // "upi" or "bank"
+	Value     string `json:"value"`     // The new account
+	FailedFor string `json:"failedFor"` // The account the transfer "failed" to
+	Turn      int    `json:"turn"`
+}
+
+// PaymentFlow is a session's progress through the failed-payment sub-flow
+type PaymentFlow struct {
+	Stage     PaymentStage
+	Attempts  int      // Failures claimed so far
+	Target    string   // Account of the latest "failed" transfer
+	Waited    int      // Scammer turns since the claim without a new account
+	Failed    []string // Every account a failure was claimed for
+	Fallbacks []FallbackAccount
+}
+
+// paymentKinds are the intel keys that can receive a transfer
+var paymentKinds = []string{"upi", "bank"}
+
+// NextPaymentTarget returns the most recently captured account no failure has
+// been claimed for yet
+func (f *PaymentFlow) NextPaymentTarget(intel Intel) (string, bool) {
+	for _, kind := range paymentKinds {
+		values := *intelGoals[kind].field(&intel)
+		for i := len(values) - 1; i >= 0; i-- {
+			if !containsString(f.Failed, values[i]) {
+				return values[i], true
+			}
+		}
+	}
+	return "", false
+}
+
+// canStart reports whether a failure can be claimed this turn
+func (f *PaymentFlow) canStart(p *FailedPaymentPolicy, intel Intel, turnCount int) bool {
+	if p == nil || f.Stage != PaymentIdle || f.Attempts >= p.MaxAttempts || MaxTurns-turnCount < p.minTurnsLeft() {
+		return false
+	}
+	_, ok := f.NextPaymentTarget(intel)
+	return ok
+}
+
+// Start records that the victim claimed the transfer to target failed
+func (f *PaymentFlow) Start(target string) {
+	f.Stage = PaymentAwaiting
+	f.Attempts++
+	f.Target = target
+	f.Waited = 0
+	f.Failed = append(f.Failed, target)
+}
+
+// Advance moves the sub-flow on after a scammer message. newIntel is what the
+// message yielded and known the intel captured before it.
+func (f *PaymentFlow) Advance(p *FailedPaymentPolicy, known, newIntel Intel, act DialogueAct, turn int) {
+	if f.Stage != PaymentAwaiting || p == nil {
+		return
+	}
+	harvested := false
+	for _, kind := range paymentKinds {
+		for _, v := range *intelGoals[kind].field(&newIntel) {
+			if containsString(*intelGoals[kind].field(&known), v) || containsString(f.Failed, v) {
+				continue
+			}
+			f.Fallbacks = append(f.Fallbacks, FallbackAccount{Kind: kind, Value: v, FailedFor: f.Target, Turn: turn})
+			harvested = true
+		}
+	}
+
+	switch {
+	case harvested && f.Attempts < p.MaxAttempts:
+		f.Stage = PaymentIdle
+	case harvested, act == ActExpressSuspicion:
+		f.Stage = PaymentFinished
+	default:
+		f.Waited++
+		if f.Waited >= p.maxWaitTurns() {
+			f.Stage = PaymentFinished
+		}
+	}
+}
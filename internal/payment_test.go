@@ -0,0 +1,103 @@
+package internal
+
+import "testing"
+
+var testPaymentPolicy = &FailedPaymentPolicy{MaxAttempts: 2}
+
+func TestCanStartFailedPayment(t *testing.T) {
+	intel := Intel{UPI: []string{"mule1@ybl"}}
+	for _, tc := range []struct {
+		name   string
+		flow   PaymentFlow
+		policy *FailedPaymentPolicy
+		intel  Intel
+		turn   int
+		want   bool
+	}{
+		{"account known", PaymentFlow{}, testPaymentPolicy, intel, 5, true},
+		{"sub-flow off", PaymentFlow{}, nil, intel, 5, false},
+		{"no account", PaymentFlow{}, testPaymentPolicy, Intel{}, 5, false},
+		{"every account failed", PaymentFlow{Failed: []string{"mule1@ybl"}}, testPaymentPolicy, intel, 5, false},
+		{"cap reached", PaymentFlow{Attempts: 2}, testPaymentPolicy, intel, 5, false},
+		{"already waiting", PaymentFlow{Stage: PaymentAwaiting}, testPaymentPolicy, intel, 5, false},
+		{"finished", PaymentFlow{Stage: PaymentFinished}, testPaymentPolicy, intel, 5, false},
+		{"last turns before the budget runs out", PaymentFlow{}, testPaymentPolicy, intel, MaxTurns - 2, false},
+		{"exactly the minimum left", PaymentFlow{}, testPaymentPolicy, intel, MaxTurns - 3, true},
+		{"lower minimum", PaymentFlow{}, &FailedPaymentPolicy{MaxAttempts: 2, MinTurnsLeft: 1}, intel, MaxTurns - 1, true},
+	} {
+		if got := tc.flow.canStart(tc.policy, tc.intel, tc.turn); got != tc.want {
+			t.Errorf("%s: canStart = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestNextPaymentTargetPrefersLatestUnfailed(t *testing.T) {
+	f := PaymentFlow{Failed: []string{"mule2@ybl"}}
+	intel := Intel{UPI: []string{"mule1@ybl", "mule2@ybl"}, Bank: []string{"123456789012"}}
+	if got, _ := f.NextPaymentTarget(intel); got != "mule1@ybl" {
+		t.Errorf("target = %s, want mule1@ybl", got)
+	}
+	f.Failed = append(f.Failed, "mule1@ybl")
+	if got, _ := f.NextPaymentTarget(intel); got != "123456789012" {
+		t.Errorf("target = %s, want the bank account once the UPI IDs failed", got)
+	}
+}
+
+func TestPaymentFlowAdvance(t *testing.T) {
+	known := Intel{UPI: []string{"mule1@ybl"}}
+
+	// A new account is tagged and the flow can start over on it
+	var f PaymentFlow
+	f.Start("mule1@ybl")
+	f.Advance(testPaymentPolicy, known, Intel{UPI: []string{"mule1@ybl", "mule2@ybl"}}, ActRequestPayment, 6)
+	if f.Stage != PaymentIdle || len(f.Fallbacks) != 1 {
+		t.Fatalf("after a new account: %+v", f)
+	}
+	if fb := f.Fallbacks[0]; fb != (FallbackAccount{Kind: "upi", Value: "mule2@ybl", FailedFor: "mule1@ybl", Turn: 6}) {
+		t.Errorf("fallback = %+v", fb)
+	}
+	known.UPI = append(known.UPI, "mule2@ybl")
+	if target, ok := f.NextPaymentTarget(known); !ok || target != "mule2@ybl" {
+		t.Fatalf("next target = %s, %v, want the fallback account", target, ok)
+	}
+
+	// The second claim reaches the cap, so its answer ends the flow
+	f.Start("mule2@ybl")
+	f.Advance(testPaymentPolicy, known, Intel{Bank: []string{"123456789012"}}, ActRequestPayment, 8)
+	if f.Stage != PaymentFinished || len(f.Fallbacks) != 2 || f.Fallbacks[1].FailedFor != "mule2@ybl" {
+		t.Errorf("after the last allowed claim: %+v", f)
+	}
+
+	// Finished flows ignore later accounts
+	f.Advance(testPaymentPolicy, known, Intel{UPI: []string{"mule3@ybl"}}, ActRequestPayment, 9)
+	if len(f.Fallbacks) != 2 {
+		t.Errorf("finished flow tagged %+v", f.Fallbacks[2:])
+	}
+}
+
+func TestPaymentFlowGivesUp(t *testing.T) {
+	known := Intel{UPI: []string{"mule1@ybl"}}
+	for _, tc := range []struct {
+		name    string
+		policy  *FailedPaymentPolicy
+		answers []DialogueAct
+		stages  []PaymentStage
+	}{
+		{"waits two turns by default", testPaymentPolicy, []DialogueAct{ActRequestPayment, ActRequestPayment}, []PaymentStage{PaymentAwaiting, PaymentFinished}},
+		{"configured wait", &FailedPaymentPolicy{MaxAttempts: 2, MaxWaitTurns: 3}, []DialogueAct{ActRequestPayment, ActRequestPayment, ActRequestPayment}, []PaymentStage{PaymentAwaiting, PaymentAwaiting, PaymentFinished}},
+		{"suspicion ends it", testPaymentPolicy, []DialogueAct{ActExpressSuspicion}, []PaymentStage{PaymentFinished}},
+	} {
+		var f PaymentFlow
+		f.Start("mule1@ybl")
+		for i, act := range tc.answers {
+			// Repeating a known or failed account is no fallback
+			f.Advance(tc.policy, known, Intel{UPI: []string{"mule1@ybl"}}, act, 5+i)
+			if f.Stage != tc.stages[i] {
+				t.Errorf("%s: turn %d stage %q, want %q", tc.name, i+1, f.Stage, tc.stages[i])
+			}
+		}
+		if len(f.Fallbacks) != 0 {
+			t.Errorf("%s: fallbacks %+v", tc.name, f.Fallbacks)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package internal
+
+import (
+	"hash/fnv"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Persona is the victim the agent plays for a whole session. Every template
+// and prompt draws on the same facts so the victim never contradicts themselves.
+type Persona struct {
+	ID            string // Key used for persona specific prompt templates
+	Name          string
+	Age           int
+	Gender        string // "male" or "female"
+	Occupation    string
+	City          string
+	Bank          string
+	Spouse        string // "wife", "husband" or "" when widowed
+	SpouseName    string
+	Child         string // "son" or "daughter"
+	ChildName     string
+	TechLiteracy  string // "very low", "low" or "medium"
+	SpeakingStyle string
+}
+
+var personas = []Persona{
+	{
+		ID:            "retired_teacher",
+		Name:          "Ramesh Sharma",
+		Age:           68,
+		Gender:        "male",
+		Occupation:    "retired school teacher",
+		City:          "Lucknow",
+		Bank:          "SBI",
+		Spouse:        "wife",
+		SpouseName:    "Sunita",
+		Child:         "son",
+		ChildName:     "Amit",
+		TechLiteracy:  "low",
+		SpeakingStyle: "polite and formal, a little long-winded, calls younger people 'beta'",
+	},
+	{
+		ID:            "widowed_homemaker",
+		Name:          "Kamala Iyer",
+		Age:           72,
+		Gender:        "female",
+		Occupation:    "homemaker",
+		City:          "Chennai",
+		Bank:          "Canara Bank",
+		Child:         "daughter",
+		ChildName:     "Priya",
+		TechLiteracy:  "very low",
+		SpeakingStyle: "anxious and apologetic, repeats herself, says her late husband used to handle the money",
+	},
+	{
+		ID:            "retired_clerk",
+		Name:          "Suresh Patil",
+		Age:           65,
+		Gender:        "male",
+		Occupation:    "retired railway clerk",
+		City:          "Pune",
+		Bank:          "Bank of Baroda",
+		Spouse:        "wife",
+		SpouseName:    "Meena",
+		Child:         "son",
+		ChildName:     "Rahul",
+		TechLiteracy:  "medium",
+		SpeakingStyle: "careful and methodical, likes to note everything down and read it back",
+	},
+	{
+		ID:            "retired_nurse",
+		Name:          "Shanti Ghosh",
+		Age:           70,
+		Gender:        "female",
+		Occupation:    "retired nurse",
+		City:          "Kolkata",
+		Bank:          "Punjab National Bank",
+		Spouse:        "husband",
+		SpouseName:    "Arun",
+		Child:         "daughter",
+		ChildName:     "Rina",
+		TechLiteracy:  "low",
+		SpeakingStyle: "warm and chatty, easily flustered by technical words",
+	},
+}
+
+// DefaultPersona is used when a session has no persona assigned
+var DefaultPersona = personas[0]
+
+// Helper is the family member the persona relies on for banking matters
+func (p Persona) Helper() string {
+	if p.Spouse != "" {
+		return p.Spouse
+	}
+	return p.Child
+}
+
+// HelperPronoun is the object pronoun for the helper ("her", "him")
+func (p Persona) HelperPronoun() string {
+	switch p.Helper() {
+	case "wife", "daughter":
+		return "her"
+	default:
+		return "him"
+	}
+}
+
+// HelperSubject is the subject pronoun for the helper ("she", "he")
+func (p Persona) HelperSubject() string {
+	if p.HelperPronoun() == "her" {
+		return "she"
+	}
+	return "he"
+}
+
+// Female reports whether gendered phrasing should use the feminine form
+func (p Persona) Female() bool {
+	return p.Gender == "female"
+}
+
+// Describe returns a one paragraph description for LLM prompts
+func (p Persona) Describe() string {
+	var sb strings.Builder
+	sb.WriteString("You are " + p.Name + ", a " + strconv.Itoa(p.Age) + "-year-old " + p.Occupation + " living in " + p.City + ". ")
+	if p.Spouse != "" {
+		sb.WriteString("Your " + p.Spouse + " is " + p.SpouseName + ". ")
+	} else {
+		sb.WriteString("You are widowed. ")
+	}
+	sb.WriteString("Your " + p.Child + " " + p.ChildName + " lives away and helps with technology. ")
+	sb.WriteString("You bank with " + p.Bank + ". ")
+	sb.WriteString("Your comfort with technology is " + p.TechLiteracy + ". ")
+	sb.WriteString("Speaking style: " + p.SpeakingStyle + ".")
+	return sb.String()
+}
+
+// GetPersona returns the built-in persona with the given ID
+func GetPersona(id string) (Persona, bool) {
+	for _, p := range personas {
+		if p.ID == id {
+			return p, true
+		}
+	}
+	return Persona{}, false
+}
+
+// SelectPersona picks a persona for a new session. PERSONA forces a specific
+// one; otherwise the choice is derived from the session ID so it is stable.
+func SelectPersona(sessionID string) Persona {
+	if id := os.Getenv("PERSONA"); id != "" {
+		if p, ok := GetPersona(id); ok {
+			return p
+		}
+		log.Printf("Unknown PERSONA %q, selecting by session", id)
+	}
+	h := fnv.New32a()
+	h.Write([]byte(sessionID))
+	return personas[h.Sum32()%uint32(len(personas))]
+}
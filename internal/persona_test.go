@@ -0,0 +1,44 @@
+package internal
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestSelectPersonaStablePerSession(t *testing.T) {
+	t.Setenv("PERSONA", "")
+	seen := map[string]bool{}
+	for i := 0; i < 40; i++ {
+		sessionID := fmt.Sprintf("session-%d", i)
+		first := SelectPersona(sessionID)
+		for run := 0; run < 3; run++ {
+			if again := SelectPersona(sessionID); again.ID != first.ID {
+				t.Fatalf("%s: got %s, first pick was %s", sessionID, again.ID, first.ID)
+			}
+		}
+		seen[first.ID] = true
+	}
+	if len(seen) < 2 {
+		t.Errorf("40 sessions all got the same persona: %v", seen)
+	}
+}
+
+func TestSelectPersonaOverride(t *testing.T) {
+	t.Setenv("PERSONA", "")
+	bySession := SelectPersona("fixed-session").ID
+
+	tests := []struct {
+		persona string
+		want    string
+	}{
+		{"retired_nurse", "retired_nurse"},
+		{"widowed_homemaker", "widowed_homemaker"},
+		{"no_such_persona", bySession},
+	}
+	for _, tt := range tests {
+		t.Setenv("PERSONA", tt.persona)
+		if got := SelectPersona("fixed-session"); got.ID != tt.want {
+			t.Errorf("PERSONA=%s: got %s, want %s", tt.persona, got.ID, tt.want)
+		}
+	}
+}
@@ -0,0 +1,431 @@
+package internal
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// The intent planning policy lives in policies/default.json, or in the file
+// named by POLICY_FILE. For every scam type and conversation state it lists
+// reactions to the scammer's dialogue act, an ordered list of intel goals and
+// a cycle of probing intents used once the goals are met. Scam types and
+// states without their own entry use the "default" policy.
+//
+//go:embed policies/default.json
+var embeddedPolicy []byte
+
+// DefaultScamType is the policy used for scam types without their own entry
+const DefaultScamType = "default"
+
+// intelGoal ties a kind of intel to the intent that asks for it
+type intelGoal struct {
+	Intent Intent
+	field  func(*Intel) *[]string
+	asked  func(*AskCount) *int
+}
+
+// intelGoals are the intel keys a policy can chase
+var intelGoals = map[string]intelGoal{
+	"upi":           {IntentAskUPI, func(i *Intel) *[]string { return &i.UPI }, func(a *AskCount) *int { return &a.UPI }},
+	"phone":         {IntentAskPhone, func(i *Intel) *[]string { return &i.Phone }, func(a *AskCount) *int { return &a.Phone }},
+	"bank":          {IntentAskBank, func(i *Intel) *[]string { return &i.Bank }, func(a *AskCount) *int { return &a.Bank }},
+	"email":         {IntentAskEmail, func(i *Intel) *[]string { return &i.Email }, func(a *AskCount) *int { return &a.Email }},
+	"link":          {IntentAskLink, func(i *Intel) *[]string { return &i.Link }, func(a *AskCount) *int { return &a.Link }},
+	"case_id":       {IntentAskCaseID, func(i *Intel) *[]string { return &i.CaseIDs }, func(a *AskCount) *int { return &a.CaseID }},
+	"ifsc":          {IntentAskIFSCCode, func(i *Intel) *[]string { return &i.IFSCCodes }, func(a *AskCount) *int { return &a.IFSCCode }},
+	"card":          {IntentAskCardNumber, func(i *Intel) *[]string { return &i.CardNumbers }, func(a *AskCount) *int { return &a.CardNumber }},
+	"policy_number": {IntentAskPolicyNumber, func(i *Intel) *[]string { return &i.PolicyNumbers }, func(a *AskCount) *int { return &a.PolicyNumber }},
+	"order_number":  {IntentAskOrderNumber, func(i *Intel) *[]string { return &i.OrderNumbers }, func(a *AskCount) *int { return &a.OrderNumber }},
+	"officer_id":    {IntentAskOfficerID, func(i *Intel) *[]string { return &i.OfficerIDs }, func(a *AskCount) *int { return &a.OfficerID }},
+	"skype":         {IntentAskSkypeID, func(i *Intel) *[]string { return &i.SkypeIDs }, func(a *AskCount) *int { return &a.SkypeID }},
+}
+
+// Record counts an ask for intel and reports whether the intent asked for intel
+func (a *AskCount) Record(intent Intent) bool {
+	for _, goal := range intelGoals {
+		if goal.Intent == intent {
+			*goal.asked(a)++
+			return true
+		}
+	}
+	return false
+}
+
+// PolicyGoal chases one kind of intel
+type PolicyGoal struct {
+	Intel   string `json:"intel"`             // Key in intelGoals, e.g. "upi"
+	MaxAsks int    `json:"maxAsks,omitempty"` // Asks before giving up, the file's maxAsks when 0
+}
+
+// PolicyReaction answers a dialogue act before the regular goals
+type PolicyReaction struct {
+	Act    DialogueAct `json:"act"`
+	Intent Intent      `json:"intent,omitempty"` // Reply intent, never used twice in a row
+	Goals  []string    `json:"goals,omitempty"`  // Intel to chase first while still missing
+}
+
+// StatePolicy plans the intents for one conversation state
+type StatePolicy struct {
+	Reactions []PolicyReaction `json:"reactions,omitempty"`
+	Goals     []PolicyGoal     `json:"goals,omitempty"`
+	Learn     bool             `json:"learn,omitempty"` // Order missing goals by learned intel yield
+	Cycle     []Intent         `json:"cycle"`           // Picked by turn number once no goal applies
+}
+
+// ScamPolicy plans the intents for one scam type
+type ScamPolicy struct {
+	ScamType      string                `json:"scamType"`
+	Reactions     []PolicyReaction      `json:"reactions,omitempty"`     // Apply in every state
+	FailedPayment *FailedPaymentPolicy  `json:"failedPayment,omitempty"` // Default policy's when nil, off when absent there
+	States        map[State]StatePolicy `json:"states"`
+}
+
+// PolicyScenario is a scripted conversation the policy must plan as expected
+type PolicyScenario struct {
+	Name     string         `json:"name"`
+	ScamType string         `json:"scamType"`
+	Turns    []ScenarioTurn `json:"turns"`
+}
+
+// ScenarioTurn is one scammer message and the intent expected in reply
+type ScenarioTurn struct {
+	Message string   `json:"message"`
+	State   State    `json:"state,omitempty"` // INTEL_EXTRACT, or PAYMENT_FAILED during the sub-flow, when empty
+	Intel   []string `json:"intel,omitempty"` // Intel keys captured from this message; a key repeated across turns yields a new value
+	Expect  Intent   `json:"expect"`
+}
+
+// PolicySet is a parsed policy file
+type PolicySet struct {
+	Version   int              `json:"version"`
+	MaxAsks   int              `json:"maxAsks"`
+	Policies  []ScamPolicy     `json:"policies"`
+	Scenarios []PolicyScenario `json:"scenarios,omitempty"`
+
+	byType map[string]*ScamPolicy
+}
+
+var (
+	policyMu      sync.RWMutex
+	currentPolicy *PolicySet
+	policyOnce    sync.Once
+	policyErr     error
+)
+
+// LoadPolicies loads and validates the planning policy and registers it for
+// reloading. It is called at startup so that a broken policy stops the server.
+func LoadPolicies() error {
+	policyOnce.Do(func() {
+		policyErr = ReloadPolicies()
+		RegisterReloader("policies", ReloadPolicies)
+	})
+	return policyErr
+}
+
+// GetPolicies returns the current planning policy, loading it on first use
+func GetPolicies() *PolicySet {
+	if err := LoadPolicies(); err != nil {
+		log.Printf("Planning policy failed to load: %v", err)
+	}
+	policyMu.RLock()
+	defer policyMu.RUnlock()
+	return currentPolicy
+}
+
+// ReloadPolicies reads the embedded policy or POLICY_FILE and swaps it in
+// only if it validates and every scenario plays out as scripted
+func ReloadPolicies() error {
+	data, source := embeddedPolicy, "embedded policy"
+	if path := os.Getenv("POLICY_FILE"); path != "" {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return err
+		}
+		source = path
+	}
+	set, err := ParsePolicies(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", source, err)
+	}
+
+	policyMu.Lock()
+	currentPolicy = set
+	policyMu.Unlock()
+	log.Printf("Loaded planning policy v%d for %d scam types (%d scenarios)", set.Version, len(set.Policies), len(set.Scenarios))
+	return nil
+}
+
+// ParsePolicies parses and validates a policy file, including its scenarios
+func ParsePolicies(data []byte) (*PolicySet, error) {
+	var set PolicySet
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+	if err := set.validate(); err != nil {
+		return nil, err
+	}
+	var errs []error
+	for _, sc := range set.Scenarios {
+		if err := set.play(sc); err != nil {
+			errs = append(errs, fmt.Errorf("scenario %q: %w", sc.Name, err))
+		}
+	}
+	return &set, errors.Join(errs...)
+}
+
+func (ps *PolicySet) validate() error {
+	var errs []error
+	if ps.MaxAsks <= 0 {
+		errs = append(errs, errors.New("maxAsks must be positive"))
+	}
+	knownIntents := map[Intent]bool{}
+	for _, intent := range AllIntents {
+		knownIntents[intent] = true
+	}
+	knownActs := map[DialogueAct]bool{}
+	for _, act := range actPriority {
+		knownActs[act] = true
+	}
+	knownStates := map[State]bool{StateInit: true, StateEngaging: true, StateIntelExtract: true, StateComplete: true, StatePaymentFailed: true}
+
+	checkReactions := func(where string, reactions []PolicyReaction) {
+		for _, r := range reactions {
+			if !knownActs[r.Act] {
+				errs = append(errs, fmt.Errorf("%s: unknown act %q", where, r.Act))
+			}
+			if r.Intent != "" && !knownIntents[r.Intent] {
+				errs = append(errs, fmt.Errorf("%s: unknown intent %q", where, r.Intent))
+			}
+			if r.Intent == "" && len(r.Goals) == 0 {
+				errs = append(errs, fmt.Errorf("%s: reaction to %s needs an intent or goals", where, r.Act))
+			}
+			for _, g := range r.Goals {
+				if _, ok := intelGoals[g]; !ok {
+					errs = append(errs, fmt.Errorf("%s: unknown intel %q", where, g))
+				}
+			}
+		}
+	}
+
+	ps.byType = map[string]*ScamPolicy{}
+	for i := range ps.Policies {
+		p := &ps.Policies[i]
+		if _, dup := ps.byType[p.ScamType]; dup {
+			errs = append(errs, fmt.Errorf("duplicate policy for %q", p.ScamType))
+		}
+		ps.byType[p.ScamType] = p
+		checkReactions(p.ScamType, p.Reactions)
+		if fp := p.FailedPayment; fp != nil && (fp.MaxAttempts < 0 || fp.MaxWaitTurns < 0 || fp.MinTurnsLeft < 0) {
+			errs = append(errs, fmt.Errorf("%s: negative failedPayment setting", p.ScamType))
+		}
+		for state, sp := range p.States {
+			where := p.ScamType + "/" + string(state)
+			if !knownStates[state] {
+				errs = append(errs, fmt.Errorf("%s: unknown state", where))
+			}
+			if len(sp.Cycle) == 0 {
+				errs = append(errs, fmt.Errorf("%s: empty cycle", where))
+			}
+			for _, intent := range sp.Cycle {
+				if !knownIntents[intent] {
+					errs = append(errs, fmt.Errorf("%s: unknown intent %q", where, intent))
+				}
+			}
+			for _, g := range sp.Goals {
+				if _, ok := intelGoals[g.Intel]; !ok {
+					errs = append(errs, fmt.Errorf("%s: unknown intel %q", where, g.Intel))
+				}
+				if g.MaxAsks < 0 {
+					errs = append(errs, fmt.Errorf("%s: negative maxAsks for %s", where, g.Intel))
+				}
+			}
+			checkReactions(where, sp.Reactions)
+		}
+	}
+
+	def, ok := ps.byType[DefaultScamType]
+	if !ok {
+		errs = append(errs, errors.New("missing default policy"))
+	} else {
+		for state := range knownStates {
+			if _, ok := def.States[state]; !ok {
+				errs = append(errs, fmt.Errorf("default policy has no %s state", state))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// scenarioValues stand in for the intel a scenario turn captures
+var scenarioValues = map[string]string{
+	"upi":           "refund.desk@ybl",
+	"phone":         "+91-9876543210",
+	"bank":          "50100234567891",
+	"email":         "support.desk@gmail.com",
+	"link":          "http://kyc-update.example.xyz",
+	"case_id":       "CASE-4471",
+	"ifsc":          "SBIN0001234",
+	"card":          "4111111111111111",
+	"policy_number": "POL123456",
+	"order_number":  "ORD778812",
+	"officer_id":    "MH-4521",
+	"skype":         "live:cbi.officer_22",
+}
+
+// scenarioValue returns the n-th distinct stand-in value for an intel key
+func scenarioValue(key string, n int) string {
+	v := scenarioValues[key]
+	if n == 0 {
+		return v
+	}
+	if at := strings.Index(v, "@"); at > 0 {
+		return v[:at] + strconv.Itoa(n) + v[at:]
+	}
+	return v + strconv.Itoa(n)
+}
+
+// play runs a scripted conversation through the planner, keeping the session
+// context the way the handler does
+func (ps *PolicySet) play(sc PolicyScenario) error {
+	var ctx SessionContext
+	seen := map[string]int{}
+	fp := ps.FailedPayment(sc.ScamType)
+	for i, turn := range sc.Turns {
+		known := ctx.Intel
+		var captured Intel
+		for _, key := range turn.Intel {
+			goal, ok := intelGoals[key]
+			if !ok {
+				return fmt.Errorf("turn %d: unknown intel %q", i+1, key)
+			}
+			*goal.field(&captured) = append(*goal.field(&captured), scenarioValue(key, seen[key]))
+			seen[key]++
+		}
+		ctx.Intel = MergeIntel(known, captured)
+		ctx.TurnCount = i + 1
+		act := ClassifyDialogueAct(turn.Message).Primary
+		ctx.Payment.Advance(fp, known, captured, act, ctx.TurnCount)
+
+		ctx.CurrentState = turn.State
+		if ctx.CurrentState == "" {
+			ctx.CurrentState = StateIntelExtract
+			if ctx.Payment.Stage == PaymentAwaiting {
+				ctx.CurrentState = StatePaymentFailed
+			}
+		}
+		got := ps.Plan(sc.ScamType, ctx, act, nil)
+		if got != turn.Expect {
+			return fmt.Errorf("turn %d (%q, %s): got %s, want %s", i+1, turn.Message, act, got, turn.Expect)
+		}
+		ctx.AskCount.Record(got)
+		ctx.LastIntent = got
+		switch got {
+		case IntentReadBack:
+			rb, _ := NextReadBack(ctx.Intel, nil)
+			ctx.Intel.Links = append(ctx.Intel.Links, IntelLink{Kind: rb.Kind, Original: rb.Original, ReadBack: rb.Altered})
+		case IntentReportPaymentFailure:
+			target, _ := ctx.Payment.NextPaymentTarget(ctx.Intel)
+			ctx.Payment.Start(target)
+		}
+	}
+	return nil
+}
+
+// FailedPayment returns the failed-payment settings for the scam type, nil when the sub-flow is off
+func (ps *PolicySet) FailedPayment(scamType string) *FailedPaymentPolicy {
+	if p, ok := ps.byType[scamType]; ok && p.FailedPayment != nil {
+		return p.FailedPayment
+	}
+	return ps.byType[DefaultScamType].FailedPayment
+}
+
+// policyFor returns the scam type's policy for the state, falling back to the default policy
+func (ps *PolicySet) policyFor(scamType string, state State) (reactions []PolicyReaction, sp StatePolicy, ok bool) {
+	def := ps.byType[DefaultScamType]
+	p, found := ps.byType[scamType]
+	if !found {
+		p = def
+	}
+	reactions = p.Reactions
+	if reactions == nil {
+		reactions = def.Reactions
+	}
+	if sp, ok = p.States[state]; !ok {
+		sp, ok = def.States[state]
+	}
+	return reactions, sp, ok
+}
+
+// Plan picks the next intent: reactions to the scammer's act first, then a
+// failed-payment claim for an account captured in INTEL_EXTRACT, then the
+// first intel goal still missing, then the probing cycle. In states marked
+// "learn" the missing goals are ranked by learned intel yield drawn from r;
+// a nil r, as in scenarios, keeps the file's order.
+func (ps *PolicySet) Plan(scamType string, ctx SessionContext, act DialogueAct, r *rand.Rand) Intent {
+	if ctx.TurnCount >= MaxTurns {
+		return IntentStall
+	}
+	reactions, sp, ok := ps.policyFor(scamType, ctx.CurrentState)
+	if !ok {
+		return IntentConfirmDetails
+	}
+
+	wants := func(key string, maxAsks int) bool {
+		goal := intelGoals[key]
+		if maxAsks == 0 {
+			maxAsks = ps.MaxAsks
+		}
+		return len(*goal.field(&ctx.Intel)) == 0 && *goal.asked(&ctx.AskCount) < maxAsks
+	}
+
+	for _, reaction := range append(append([]PolicyReaction(nil), reactions...), sp.Reactions...) {
+		if reaction.Act != act {
+			continue
+		}
+		if reaction.Intent != "" && reaction.Intent != ctx.LastIntent && usable(reaction.Intent, ctx.Intel) {
+			return reaction.Intent
+		}
+		for _, key := range reaction.Goals {
+			if wants(key, 0) {
+				return intelGoals[key].Intent
+			}
+		}
+	}
+	if ctx.CurrentState == StateIntelExtract && ctx.Payment.canStart(ps.FailedPayment(scamType), ctx.Intel, ctx.TurnCount) {
+		return IntentReportPaymentFailure
+	}
+	var missing []Intent
+	for _, g := range sp.Goals {
+		if wants(g.Intel, g.MaxAsks) {
+			missing = append(missing, intelGoals[g.Intel].Intent)
+		}
+	}
+	if len(missing) > 0 {
+		if sp.Learn && r != nil {
+			missing = GetStrategy().orderGoals(scamType, missing, r)
+		}
+		return missing[0]
+	}
+	if intent := sp.Cycle[ctx.TurnCount%len(sp.Cycle)]; usable(intent, ctx.Intel) {
+		return intent
+	}
+	return IntentConfirmDetails
+}
+
+// usable reports whether the intent has what it needs, such as an identifier
+// left to read back
+func usable(intent Intent, intel Intel) bool {
+	if intent == IntentReadBack {
+		_, ok := NextReadBack(intel, nil)
+		return ok
+	}
+	return true
+}
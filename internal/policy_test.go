@@ -0,0 +1,115 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+)
+
+func embeddedPolicySet(t *testing.T) *PolicySet {
+	t.Helper()
+	ps, err := ParsePolicies(embeddedPolicy)
+	if err != nil {
+		t.Fatalf("embedded policy: %v", err)
+	}
+	return ps
+}
+
+func TestEmbeddedPolicyScenarios(t *testing.T) {
+	ps := embeddedPolicySet(t)
+	if len(ps.Scenarios) == 0 {
+		t.Fatal("embedded policy has no scenarios")
+	}
+	for _, sc := range ps.Scenarios {
+		t.Run(sc.Name, func(t *testing.T) {
+			if err := ps.play(sc); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+// TestScriptedConversations plays conversations the policy file does not
+// script itself through the embedded policy
+func TestScriptedConversations(t *testing.T) {
+	ps := embeddedPolicySet(t)
+	for _, sc := range []PolicyScenario{
+		{
+			Name:     "goals asked up to maxAsks in file order",
+			ScamType: "tech_support_fraud",
+			Turns: []ScenarioTurn{
+				{Message: "ok", Expect: IntentAskUPI},
+				{Message: "ok", Expect: IntentAskUPI},
+				{Message: "ok", Expect: IntentAskPhone},
+				{Message: "ok", Expect: IntentAskPhone},
+				{Message: "ok", Expect: IntentAskBank},
+			},
+		},
+		{
+			Name:     "credential demands alternate deflecting and fake values",
+			ScamType: "bank_fraud",
+			Turns: []ScenarioTurn{
+				{Message: "Share your OTP", Expect: IntentDeflectCredential},
+				{Message: "Tell me the OTP", Expect: IntentGiveHoneytoken},
+				{Message: "Share the PIN now", Expect: IntentDeflectCredential},
+				{Message: "Are you a bot?", Expect: IntentReassure},
+			},
+		},
+		{
+			Name:     "early states cycle without chasing intel",
+			ScamType: "delivery_fraud",
+			Turns: []ScenarioTurn{
+				{Message: "Hello", State: StateInit, Expect: IntentConfirmDetails},
+				{Message: "Your parcel is waiting", State: StateInit, Expect: IntentAskIdentity},
+				{Message: "ok", State: StateEngaging, Expect: IntentAskIdentity},
+				{Message: "ok", State: StateEngaging, Expect: IntentDeepProbe},
+			},
+		},
+		{
+			Name:     "scam type policy overrides the default goals",
+			ScamType: "delivery_fraud",
+			Turns: []ScenarioTurn{
+				{Message: "ok", Expect: IntentAskLink},
+				{Message: "Pay the customs fee at the link now", Intel: []string{"link"}, Expect: IntentAskUPI},
+				{Message: "ok", Expect: IntentAskUPI},
+				{Message: "ok", Expect: IntentAskOrderNumber},
+			},
+		},
+	} {
+		t.Run(sc.Name, func(t *testing.T) {
+			if err := ps.play(sc); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestPlanStallsOnceTurnsRunOut(t *testing.T) {
+	ps := embeddedPolicySet(t)
+	ctx := SessionContext{CurrentState: StateIntelExtract, TurnCount: MaxTurns}
+	if got := ps.Plan("bank_fraud", ctx, ActRequestCredential, nil); got != IntentStall {
+		t.Errorf("Plan at the turn limit = %s, want STALL", got)
+	}
+}
+
+func TestParsePoliciesRejectsBrokenFiles(t *testing.T) {
+	valid := string(embeddedPolicy)
+	for name, tc := range map[string]struct {
+		old, new string
+		want     string
+	}{
+		"scenario expecting another intent": {`"expect": "ASK_OFFICER_ID"`, `"expect": "ASK_UPI"`, `scenario "digital arrest on skype"`},
+		"unknown intent in a cycle":         {`"cycle": ["STALL"]`, `"cycle": ["SING"]`, `unknown intent "SING"`},
+		"unknown intel goal":                {`{ "intel": "email" }`, `{ "intel": "fax" }`, `unknown intel "fax"`},
+		"unknown dialogue act":              {`"act": "EXPRESS_SUSPICION"`, `"act": "SHOUT"`, `unknown act "SHOUT"`},
+		"missing default policy":            {`"scamType": "default"`, `"scamType": "fallback"`, "missing default policy"},
+		"no asks allowed":                   {`"maxAsks": 2`, `"maxAsks": 0`, "maxAsks must be positive"},
+	} {
+		if !strings.Contains(valid, tc.old) {
+			t.Fatalf("%s: %q not in the embedded policy", name, tc.old)
+		}
+		_, err := ParsePolicies([]byte(strings.Replace(valid, tc.old, tc.new, 1)))
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: error = %v, want one containing %q", name, err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,261 @@
+package internal
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// Prompt templates live in prompts/<locale>/<persona>/<name>.tmpl where name is
+// "system" or an Intent (e.g. ASK_UPI). "default" is used for any locale or
+// persona without its own file. The embedded set can be overridden file by
+// file from the directory in PROMPT_DIR.
+//
+//go:embed prompts
+var embeddedPrompts embed.FS
+
+const defaultPromptKey = "default"
+
+// PromptData is the data available to every prompt template
+type PromptData struct {
+	Intent      Intent
+	Instruction string // Rendered intent template, available to the system template
+	Persona     Persona
+	Locale      string
+	Language    string // Which language and script to reply in, see LanguageInstruction
+	ScamType    string
+	ClaimedOrg  string
+	Intel       Intel
+	KnownIntel  []string // Human readable list of the intel captured so far
+	Facts       []string // Claims the victim already made in this conversation
+	TurnCount   int
+	TurnBudget  int
+	TurnsLeft   int
+
+	Act                DialogueAct // What the latest message does, see ClassifyDialogueAct
+	InjectionSuspected bool        // The latest message tries to instruct the model
+
+	ReadBack      string   // For READ_BACK: the identifier with its deliberate mistake
+	ReadBackLabel string   // What the identifier is, e.g. "bank account number"
+	FailedAccount string   // For the failed-payment sub-flow: where the transfer "failed" to
+	Honeytokens   []string // For GIVE_HONEYTOKEN: the fake values to hand over, e.g. "OTP 482913"
+	CanaryURL     string   // For SEND_CANARY: the link to send, empty when canaries are off
+	CanaryLabel   string   // What the link claims to be, e.g. "payment screenshot"
+}
+
+// PromptStore holds the parsed prompt templates
+type PromptStore struct {
+	mu        sync.RWMutex
+	templates map[string]*template.Template // key: locale/persona/name
+}
+
+var (
+	promptStore     = &PromptStore{}
+	promptStoreOnce sync.Once
+	promptStoreErr  error
+)
+
+// LoadPrompts loads and validates the prompt templates and registers them for
+// reloading. It is called at startup so that broken templates stop the server.
+func LoadPrompts() error {
+	promptStoreOnce.Do(func() {
+		promptStoreErr = promptStore.Reload()
+		RegisterReloader("prompts", promptStore.Reload)
+	})
+	return promptStoreErr
+}
+
+// GetPromptStore returns the prompt store, loading it on first use
+func GetPromptStore() *PromptStore {
+	if err := LoadPrompts(); err != nil {
+		log.Printf("Prompt templates failed to load: %v", err)
+	}
+	return promptStore
+}
+
+var promptFuncs = template.FuncMap{
+	"join":  strings.Join,
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+}
+
+// Reload parses the embedded templates plus PROMPT_DIR overrides and swaps
+// them in only if the whole set validates
+func (ps *PromptStore) Reload() error {
+	templates := map[string]*template.Template{}
+
+	sub, err := fs.Sub(embeddedPrompts, "prompts")
+	if err != nil {
+		return err
+	}
+	if err := parsePromptFS(sub, templates); err != nil {
+		return fmt.Errorf("embedded prompts: %w", err)
+	}
+	if dir := os.Getenv("PROMPT_DIR"); dir != "" {
+		if err := parsePromptFS(os.DirFS(dir), templates); err != nil {
+			return fmt.Errorf("%s: %w", dir, err)
+		}
+	}
+	if err := validatePrompts(templates); err != nil {
+		return err
+	}
+
+	ps.mu.Lock()
+	ps.templates = templates
+	ps.mu.Unlock()
+	log.Printf("Loaded %d prompt templates", len(templates))
+	return nil
+}
+
+func parsePromptFS(fsys fs.FS, templates map[string]*template.Template) error {
+	return fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || path.Ext(p) != ".tmpl" {
+			return nil
+		}
+		parts := strings.Split(p, "/")
+		if len(parts) != 3 {
+			return fmt.Errorf("%s: expected <locale>/<persona>/<name>.tmpl", p)
+		}
+		data, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return err
+		}
+		key := strings.ToLower(parts[0]) + "/" + strings.ToLower(parts[1]) + "/" + strings.TrimSuffix(parts[2], ".tmpl")
+		tmpl, err := template.New(key).Funcs(promptFuncs).Option("missingkey=error").Parse(string(data))
+		if err != nil {
+			return err
+		}
+		templates[key] = tmpl
+		return nil
+	})
+}
+
+// validatePrompts checks that the default set covers the system prompt and
+// every intent, and that every template renders with sample data
+func validatePrompts(templates map[string]*template.Template) error {
+	var errs []error
+	base := defaultPromptKey + "/" + defaultPromptKey + "/"
+	if _, ok := templates[base+"system"]; !ok {
+		errs = append(errs, errors.New("missing default system template"))
+	}
+	for _, intent := range AllIntents {
+		if _, ok := templates[base+string(intent)]; !ok {
+			errs = append(errs, fmt.Errorf("missing default template for intent %s", intent))
+		}
+	}
+
+	sample := PromptData{
+		Intent:      IntentAskUPI,
+		Instruction: "Ask for the UPI ID.",
+		Persona:     DefaultPersona,
+		Locale:      defaultPromptKey,
+		Language:    LanguageInstruction(LocaleEnglish),
+		ScamType:    "bank_fraud",
+		ClaimedOrg:  "SBI",
+		Intel:       Intel{UPI: []string{"sample@ybl"}},
+		KnownIntel:  []string{"UPI ID sample@ybl"},
+		Facts:       []string{"location: market (turn 2)"},
+		TurnCount:   3,
+		TurnBudget:  MaxTurns,
+		TurnsLeft:   MaxTurns - 3,
+		Act:         ActAskQuestion,
+
+		ReadBack:      "sample@ibl",
+		ReadBackLabel: readBackLabels["upi"],
+		FailedAccount: "sample@ybl",
+	}
+	for key, tmpl := range templates {
+		if err := tmpl.Execute(&bytes.Buffer{}, sample); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// lookup finds the most specific template for name, falling back from the
+// exact locale to its base language and from the persona to "default"
+func (ps *PromptStore) lookup(name, persona, locale string) *template.Template {
+	ps.mu.RLock()
+	defer ps.mu.RUnlock()
+
+	persona = strings.ToLower(persona)
+	locale = strings.ToLower(locale)
+	locales := []string{}
+	if locale != "" {
+		locales = append(locales, locale)
+		if i := strings.IndexAny(locale, "-_"); i > 0 {
+			locales = append(locales, locale[:i])
+		}
+	}
+	locales = append(locales, defaultPromptKey)
+	personas := []string{defaultPromptKey}
+	if persona != "" && persona != defaultPromptKey {
+		personas = []string{persona, defaultPromptKey}
+	}
+
+	for _, l := range locales {
+		for _, p := range personas {
+			if tmpl, ok := ps.templates[l+"/"+p+"/"+name]; ok {
+				return tmpl
+			}
+		}
+	}
+	return nil
+}
+
+// Render executes the named template for the persona and locale in data
+func (ps *PromptStore) Render(name string, data PromptData) (string, error) {
+	tmpl := ps.lookup(name, data.Persona.ID, data.Locale)
+	if tmpl == nil {
+		return "", fmt.Errorf("no prompt template %q", name)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// SystemPrompt renders the intent instruction and then the system prompt
+func (ps *PromptStore) SystemPrompt(data PromptData) (string, error) {
+	instruction, err := ps.Render(string(data.Intent), data)
+	if err != nil {
+		instruction = "Respond naturally and ask a follow-up question."
+	}
+	data.Instruction = instruction
+	return ps.Render("system", data)
+}
+
+// describeIntel lists captured intel in a form suitable for prompts
+func describeIntel(intel Intel) []string {
+	var items []string
+	add := func(label string, values []string) {
+		for _, v := range values {
+			items = append(items, label+" "+v)
+		}
+	}
+	add("UPI ID", intel.UPI)
+	add("phone number", intel.Phone)
+	add("bank account", intel.Bank)
+	add("IFSC code", intel.IFSCCodes)
+	add("email", intel.Email)
+	add("link", intel.Link)
+	add("case ID", intel.CaseIDs)
+	add("policy number", intel.PolicyNumbers)
+	add("order number", intel.OrderNumbers)
+	add("card number", intel.CardNumbers)
+	add("officer ID", intel.OfficerIDs)
+	add("Skype ID", intel.SkypeIDs)
+	return items
+}
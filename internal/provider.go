@@ -0,0 +1,216 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Provider describes an OpenAI-compatible chat completions backend
+// (Groq, Ollama, llama.cpp server, vLLM, ...)
+type Provider struct {
+	Name        string
+	BaseURL     string // e.g. https://api.groq.com/openai/v1
+	Model       string
+	APIKey      string
+	AuthHeader  string // Header carrying the key, usually "Authorization"
+	AuthScheme  string // Prefix before the key, usually "Bearer"; empty sends the raw key
+	Temperature float64
+	MaxTokens   int
+	RequireKey  bool // Skip the provider when no API key is configured
+}
+
+// builtinProviders holds defaults for well known backends; every field can be
+// overridden with LLM_<NAME>_* environment variables
+var builtinProviders = map[string]Provider{
+	"groq": {
+		BaseURL:    "https://api.groq.com/openai/v1",
+		Model:      "llama-3.1-8b-instant",
+		AuthHeader: "Authorization",
+		AuthScheme: "Bearer",
+		RequireKey: true,
+	},
+	"ollama": {
+		BaseURL:    "http://localhost:11434/v1",
+		Model:      "llama3.1",
+		AuthHeader: "Authorization",
+		AuthScheme: "Bearer",
+	},
+	"llamacpp": {
+		BaseURL:    "http://localhost:8081/v1",
+		Model:      "local",
+		AuthHeader: "Authorization",
+		AuthScheme: "Bearer",
+	},
+	"vllm": {
+		BaseURL:    "http://localhost:8000/v1",
+		Model:      "meta-llama/Llama-3.1-8B-Instruct",
+		AuthHeader: "Authorization",
+		AuthScheme: "Bearer",
+	},
+}
+
+const (
+	defaultTemperature = 0.7
+	defaultMaxTokens   = 120
+)
+
+type ChatRequest struct {
+	Model       string        `json:"model"`
+	Messages    []ChatMessage `json:"messages"`
+	Temperature float64       `json:"temperature"`
+	MaxTokens   int           `json:"max_tokens,omitempty"`
+}
+
+type ChatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type ChatResponse struct {
+	Choices []struct {
+		Message struct {
+			Content string `json:"content"`
+		} `json:"message"`
+	} `json:"choices"`
+}
+
+var (
+	providers     []Provider
+	providersOnce sync.Once
+)
+
+// GetProviders returns the usable providers in fallback order
+func GetProviders() []Provider {
+	providersOnce.Do(func() {
+		providers = LoadProvidersFromEnv()
+		names := make([]string, 0, len(providers))
+		for _, p := range providers {
+			names = append(names, p.Name+"("+p.Model+")")
+		}
+		log.Printf("LLM providers: %s", strings.Join(names, " -> "))
+	})
+	return providers
+}
+
+// LoadProvidersFromEnv reads the provider list from configuration:
+//
+//	LLM_PROVIDERS            comma separated names in fallback order (default "groq")
+//	LLM_<NAME>_BASE_URL      base URL up to and including /v1 (groq also reads GROQ_BASE_URL)
+//	LLM_<NAME>_MODEL         model name
+//	LLM_<NAME>_API_KEY       API key (groq also reads GROQ_API_KEY)
+//	LLM_<NAME>_AUTH_HEADER   header carrying the key (default Authorization)
+//	LLM_<NAME>_AUTH_SCHEME   key prefix (default Bearer, "none" for a raw key)
+//	LLM_<NAME>_TEMPERATURE   sampling temperature (default 0.7)
+//	LLM_<NAME>_MAX_TOKENS    completion token limit (default 120)
+//
+// Names that are not built in must at least set BASE_URL and MODEL.
+func LoadProvidersFromEnv() []Provider {
+	list := os.Getenv("LLM_PROVIDERS")
+	if strings.TrimSpace(list) == "" {
+		list = "groq"
+	}
+
+	var result []Provider
+	for _, name := range strings.Split(list, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		p, err := providerFromEnv(name)
+		if err != nil {
+			log.Printf("Skipping LLM provider %s: %v", name, err)
+			continue
+		}
+		result = append(result, p)
+	}
+	return result
+}
+
+func providerFromEnv(name string) (Provider, error) {
+	p := builtinProviders[name]
+	p.Name = name
+	if p.Temperature == 0 {
+		p.Temperature = defaultTemperature
+	}
+	if p.MaxTokens == 0 {
+		p.MaxTokens = defaultMaxTokens
+	}
+	if p.AuthHeader == "" {
+		p.AuthHeader = "Authorization"
+		p.AuthScheme = "Bearer"
+	}
+
+	prefix := "LLM_" + strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(name)) + "_"
+	if v := os.Getenv(prefix + "BASE_URL"); v != "" {
+		p.BaseURL = v
+	} else if v := os.Getenv("GROQ_BASE_URL"); v != "" && name == "groq" {
+		p.BaseURL = v
+	}
+	if v := os.Getenv(prefix + "MODEL"); v != "" {
+		p.Model = v
+	}
+	p.APIKey = os.Getenv(prefix + "API_KEY")
+	if p.APIKey == "" && name == "groq" {
+		p.APIKey = os.Getenv("GROQ_API_KEY")
+	}
+	if v := os.Getenv(prefix + "AUTH_HEADER"); v != "" {
+		p.AuthHeader = v
+	}
+	if v := os.Getenv(prefix + "AUTH_SCHEME"); v != "" {
+		p.AuthScheme = v
+		if strings.EqualFold(v, "none") {
+			p.AuthScheme = ""
+		}
+	}
+	if v := os.Getenv(prefix + "TEMPERATURE"); v != "" {
+		t, err := strconv.ParseFloat(v, 64)
+		if err != nil || t < 0 || t > 2 {
+			return p, fmt.Errorf("invalid %sTEMPERATURE %q", prefix, v)
+		}
+		p.Temperature = t
+	}
+	if v := os.Getenv(prefix + "MAX_TOKENS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return p, fmt.Errorf("invalid %sMAX_TOKENS %q", prefix, v)
+		}
+		p.MaxTokens = n
+	}
+
+	if p.BaseURL == "" || p.Model == "" {
+		return p, errors.New("base URL and model are required")
+	}
+	if p.RequireKey && p.APIKey == "" {
+		return p, errors.New("API key not set")
+	}
+	p.BaseURL = strings.TrimRight(p.BaseURL, "/")
+	return p, nil
+}
+
+// CompleteWithFallback tries each provider in order until one answers.
+// Providers whose circuit breaker is open are skipped without a network call.
+func CompleteWithFallback(ctx context.Context, providers []Provider, messages []ChatMessage) (string, string, error) {
+	if len(providers) == 0 {
+		return "", "", ErrLLMUnavailable
+	}
+	client := GetLLMClient()
+	var errs []error
+	for _, p := range providers {
+		reply, err := client.Complete(ctx, p, messages)
+		if err == nil {
+			return reply, p.Name, nil
+		}
+		log.Printf("LLM provider %s failed: %v", p.Name, err)
+		errs = append(errs, err)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return "", "", errors.Join(errs...)
+}
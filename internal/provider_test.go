@@ -0,0 +1,163 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// useLLMClient replaces the shared LLM client for the duration of the test
+func useLLMClient(t *testing.T, cfg LLMClientConfig) {
+	t.Helper()
+	GetLLMClient()
+	saved := llmClient
+	llmClient = NewLLMClient(cfg)
+	t.Cleanup(func() { llmClient = saved })
+}
+
+// fastClientConfig retries quickly and never opens the breaker
+var fastClientConfig = LLMClientConfig{
+	CallTimeout: time.Second,
+	BaseDelay:   time.Millisecond,
+	MaxDelay:    5 * time.Millisecond,
+}
+
+// chatServer answers chat completions with the reply, or with the status when
+// it is not 200, and records the last request
+type chatServer struct {
+	*httptest.Server
+	calls   atomic.Int32
+	lastReq ChatRequest
+	auth    string
+}
+
+func newChatServer(t *testing.T, status int, reply string) *chatServer {
+	t.Helper()
+	s := &chatServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.calls.Add(1)
+		if r.URL.Path != "/chat/completions" {
+			http.NotFound(w, r)
+			return
+		}
+		s.auth = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&s.lastReq)
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			return
+		}
+		fmt.Fprintf(w, `{"choices":[{"message":{"role":"assistant","content":%q}}]}`, reply)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestLoadProvidersFromEnv(t *testing.T) {
+	t.Setenv("LLM_PROVIDERS", " OnPrem, groq ,ollama")
+	t.Setenv("LLM_ONPREM_BASE_URL", "http://10.0.0.5:8000/v1/")
+	t.Setenv("LLM_ONPREM_MODEL", "qwen2.5")
+	t.Setenv("LLM_ONPREM_AUTH_SCHEME", "none")
+	t.Setenv("LLM_ONPREM_API_KEY", "secret")
+	t.Setenv("LLM_ONPREM_MAX_TOKENS", "80")
+	t.Setenv("LLM_GROQ_API_KEY", "")
+	t.Setenv("GROQ_API_KEY", "")
+	t.Setenv("LLM_OLLAMA_TEMPERATURE", "0.2")
+
+	got := LoadProvidersFromEnv()
+	if len(got) != 2 {
+		t.Fatalf("got %d providers, want onprem and ollama (groq has no key): %+v", len(got), got)
+	}
+	onprem, ollama := got[0], got[1]
+	if onprem.Name != "onprem" || onprem.BaseURL != "http://10.0.0.5:8000/v1" || onprem.Model != "qwen2.5" {
+		t.Errorf("onprem = %+v", onprem)
+	}
+	if onprem.AuthScheme != "" || onprem.APIKey != "secret" || onprem.MaxTokens != 80 || onprem.Temperature != defaultTemperature {
+		t.Errorf("onprem settings = %+v", onprem)
+	}
+	if ollama.Name != "ollama" || ollama.BaseURL != builtinProviders["ollama"].BaseURL || ollama.Temperature != 0.2 {
+		t.Errorf("ollama = %+v", ollama)
+	}
+}
+
+func TestLoadProvidersFromEnvSkipsInvalid(t *testing.T) {
+	t.Setenv("LLM_PROVIDERS", "custom,vllm")
+	t.Setenv("LLM_CUSTOM_BASE_URL", "http://localhost:9000/v1")
+	t.Setenv("LLM_VLLM_MAX_TOKENS", "lots")
+
+	if got := LoadProvidersFromEnv(); len(got) != 0 {
+		t.Errorf("got %+v, want none: custom has no model and vllm an invalid token limit", got)
+	}
+}
+
+func TestLoadProvidersFromEnvDefaultsToGroq(t *testing.T) {
+	t.Setenv("LLM_PROVIDERS", "")
+	t.Setenv("GROQ_API_KEY", "gsk_test")
+
+	got := LoadProvidersFromEnv()
+	if len(got) != 1 || got[0].Name != "groq" || got[0].APIKey != "gsk_test" {
+		t.Errorf("got %+v, want groq with GROQ_API_KEY", got)
+	}
+}
+
+func TestCompleteWithFallback(t *testing.T) {
+	useLLMClient(t, fastClientConfig)
+	down := newChatServer(t, http.StatusInternalServerError, "")
+	up := newChatServer(t, http.StatusOK, "Which branch is this?")
+	list := []Provider{
+		{Name: "down", BaseURL: down.URL, Model: "a"},
+		{Name: "up", BaseURL: up.URL, Model: "b", APIKey: "key", AuthHeader: "Authorization", AuthScheme: "Bearer", Temperature: 0.3, MaxTokens: 50},
+	}
+	messages := []ChatMessage{{Role: "user", Content: "hello"}}
+
+	reply, name, err := CompleteWithFallback(context.Background(), list, messages)
+	if err != nil {
+		t.Fatalf("CompleteWithFallback: %v", err)
+	}
+	if reply != "Which branch is this?" || name != "up" {
+		t.Errorf("got %q from %s", reply, name)
+	}
+	if up.auth != "Bearer key" {
+		t.Errorf("Authorization = %q", up.auth)
+	}
+	if up.lastReq.Model != "b" || up.lastReq.Temperature != 0.3 || up.lastReq.MaxTokens != 50 || len(up.lastReq.Messages) != 1 {
+		t.Errorf("request = %+v", up.lastReq)
+	}
+}
+
+func TestCompleteWithFallbackAllFail(t *testing.T) {
+	useLLMClient(t, fastClientConfig)
+	down := newChatServer(t, http.StatusBadRequest, "")
+
+	_, _, err := CompleteWithFallback(context.Background(), []Provider{{Name: "down", BaseURL: down.URL}}, nil)
+	if err == nil {
+		t.Fatal("no error when every provider fails")
+	}
+	if _, _, err := CompleteWithFallback(context.Background(), nil, nil); err != ErrLLMUnavailable {
+		t.Errorf("no providers: error = %v, want ErrLLMUnavailable", err)
+	}
+}
+
+func TestLLMResponderThroughProvider(t *testing.T) {
+	useLLMClient(t, fastClientConfig)
+	server := newChatServer(t, http.StatusOK, "Sir, which branch are you calling from?")
+	useProviders(t, []Provider{{Name: "test", BaseURL: server.URL, Model: "m"}})
+
+	req := templateRequest(IntentAskIdentity)
+	req.History = []ChatTurn{{Sender: SenderScammer, Text: "This is the SBI fraud department"}}
+	reply, err := LLMResponder{Timeout: time.Second}.Respond(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Respond: %v", err)
+	}
+	if reply != "Sir, which branch are you calling from?" {
+		t.Errorf("reply = %q", reply)
+	}
+	msgs := server.lastReq.Messages
+	if len(msgs) < 2 || msgs[0].Role != "system" || msgs[len(msgs)-1].Role != "user" {
+		t.Errorf("messages = %+v, want a system prompt then the scammer's turn", msgs)
+	}
+}
@@ -0,0 +1,190 @@
+package internal
+
+import (
+	"math/rand"
+	"regexp"
+	"strings"
+)
+
+// A read-back repeats a captured identifier to the scammer with a plausible
+// mistake in it: two digits swapped in an account or phone number, or the
+// wrong PSP suffix on a UPI ID. Scammers correct eagerly, and the correction
+// confirms the original, repeats it or brings out an alternative account.
+
+// ReadBack is an identifier the victim reads back with a deliberate mistake
+type ReadBack struct {
+	Kind     string // Intel key: "bank", "upi" or "phone"
+	Original string // Value as captured
+	Altered  string // Value as read back
+	Turn     int
+}
+
+// IntelLink ties the scammer's answer to a read-back to the original value
+type IntelLink struct {
+	Kind     string `json:"kind"`
+	Original string `json:"original"`
+	ReadBack string `json:"readBack"`        // The altered value we read back
+	Value    string `json:"value,omitempty"` // Corrected or alternative value
+	Relation string `json:"relation"`        // See the Link* constants
+	Turn     int    `json:"turn"`
+}
+
+// Relations between a read-back and the scammer's answer
+const (
+	LinkCorrected   = "corrected"   // Repeated the original value
+	LinkAlternative = "alternative" // Gave a different value of the same kind
+	LinkRejected    = "rejected"    // Said it was wrong without a value
+	LinkAccepted    = "accepted"    // Agreed with the altered value
+	LinkIgnored     = "ignored"     // Did not react
+)
+
+// readBackKinds are the intel keys worth reading back, most valuable first
+var readBackKinds = []string{"bank", "upi", "phone"}
+
+// readBackLabels name each kind in prompts
+var readBackLabels = map[string]string{
+	"bank":  "bank account number",
+	"upi":   "UPI ID",
+	"phone": "phone number",
+}
+
+// confusablePSPs maps a UPI handle suffix to one that is easy to mishear
+var confusablePSPs = map[string]string{
+	"ybl":        "ibl",
+	"ibl":        "ybl",
+	"axl":        "okaxis",
+	"okaxis":     "axl",
+	"axisbank":   "okaxis",
+	"oksbi":      "sbi",
+	"sbi":        "oksbi",
+	"okhdfcbank": "hdfcbank",
+	"hdfcbank":   "okhdfcbank",
+	"hdfc":       "okhdfcbank",
+	"okicici":    "icici",
+	"icici":      "okicici",
+	"paytm":      "ptyes",
+	"apl":        "axl",
+	"upi":        "ybl",
+}
+
+var (
+	regexAffirm = regexp.MustCompile(`(?i)\b(yes|yeah|yep|yup|correct|right|exactly|ok|okay|haan|han|ji|sahi|theek|bilkul)\b`)
+	regexDeny   = regexp.MustCompile(`(?i)\b(no|nope|not\s+correct|wrong|incorrect|mistake|galat|nahi|nahin)\b`)
+	// regexBenignNo is a "no" that denies nothing: short for number ("account
+	// no 12345", "no. is") or in "no problem"
+	regexBenignNo = regexp.MustCompile(`(?i)\bno(\.|\s*[:#]?\s*\d|\s+(problem|doubt|issue|worries)\b)`)
+)
+
+// NextReadBack picks the latest captured identifier that has not been read
+// back yet and alters it. With a nil r the choice is deterministic.
+func NextReadBack(intel Intel, r *rand.Rand) (ReadBack, bool) {
+	done := map[string]bool{}
+	for _, link := range intel.Links {
+		done[link.Original] = true
+	}
+	for _, kind := range readBackKinds {
+		values := *intelGoals[kind].field(&intel)
+		for i := len(values) - 1; i >= 0; i-- {
+			value := values[i]
+			if done[value] {
+				continue
+			}
+			if altered, ok := alterValue(kind, value, r); ok {
+				return ReadBack{Kind: kind, Original: value, Altered: altered}, true
+			}
+		}
+	}
+	return ReadBack{}, false
+}
+
+// alterValue introduces a plausible mistake into the value
+func alterValue(kind, value string, r *rand.Rand) (string, bool) {
+	if kind == "upi" {
+		at := strings.LastIndex(value, "@")
+		if at <= 0 {
+			return "", false
+		}
+		psp := confusablePSPs[value[at+1:]]
+		if psp == "" {
+			psp = "ybl"
+			if value[at+1:] == psp {
+				psp = "ibl"
+			}
+		}
+		return value[:at+1] + psp, true
+	}
+
+	// Swap two neighbouring digits, preferably in the second half where a
+	// slip is least suspicious
+	var pos []int
+	for i := 0; i+1 < len(value); i++ {
+		if isDigit(value[i]) && isDigit(value[i+1]) && value[i] != value[i+1] {
+			pos = append(pos, i)
+		}
+	}
+	if len(pos) == 0 {
+		return "", false
+	}
+	var late []int
+	for _, i := range pos {
+		if i >= len(value)/2 {
+			late = append(late, i)
+		}
+	}
+	if len(late) > 0 {
+		pos = late
+	}
+	i := pos[0]
+	if r != nil {
+		i = pos[r.Intn(len(pos))]
+	}
+	b := []byte(value)
+	b[i], b[i+1] = b[i+1], b[i]
+	return string(b), true
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+// ResolveReadBack records how the scammer answered a read-back and drops every
+// altered value we read back from the intel, since the scammer echoing our
+// mistake does not make it theirs. newIntel is what the latest message yielded.
+func ResolveReadBack(intel Intel, pending *ReadBack, message string, newIntel Intel, turn int) Intel {
+	if pending != nil {
+		link := IntelLink{Kind: pending.Kind, Original: pending.Original, ReadBack: pending.Altered, Turn: turn}
+		var values []string
+		for _, v := range *intelGoals[pending.Kind].field(&newIntel) {
+			if v != pending.Altered {
+				values = append(values, v)
+			}
+		}
+		switch {
+		case containsString(values, pending.Original):
+			link.Relation, link.Value = LinkCorrected, pending.Original
+		case len(values) > 0:
+			link.Relation, link.Value = LinkAlternative, values[0]
+		case regexDeny.MatchString(regexBenignNo.ReplaceAllString(message, " ")):
+			link.Relation = LinkRejected
+		case regexAffirm.MatchString(message) || containsString(*intelGoals[pending.Kind].field(&newIntel), pending.Altered):
+			link.Relation = LinkAccepted
+		default:
+			link.Relation = LinkIgnored
+		}
+		intel.Links = append(intel.Links, link)
+	}
+	return DropReadBacks(intel, intel.Links)
+}
+
+// DropReadBacks removes the altered values of the read-backs from intel
+func DropReadBacks(intel Intel, links []IntelLink) Intel {
+	for _, link := range links {
+		field := intelGoals[link.Kind].field(&intel)
+		kept := (*field)[:0:0]
+		for _, v := range *field {
+			if v != link.ReadBack {
+				kept = append(kept, v)
+			}
+		}
+		*field = kept
+	}
+	return intel
+}
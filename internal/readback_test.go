@@ -0,0 +1,93 @@
+package internal
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestAlterValue(t *testing.T) {
+	for _, tc := range []struct {
+		kind, value, want string
+		ok                bool
+	}{
+		{"upi", "fraud.desk@ybl", "fraud.desk@ibl", true},
+		{"upi", "fraud.desk@oksbi", "fraud.desk@sbi", true},
+		{"upi", "fraud.desk@unknownpsp", "fraud.desk@ybl", true},
+		{"upi", "fraud.desk@UPI", "fraud.desk@ybl", true},
+		{"upi", "no-handle", "", false},
+		{"bank", "123456789012", "123456879012", true}, // First pair in the second half
+		{"phone", "+91 98765 43210", "+91 98756 43210", true},
+		{"bank", "111111", "", false},
+	} {
+		got, ok := alterValue(tc.kind, tc.value, nil)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("alterValue(%s, %s) = %q, %v, want %q, %v", tc.kind, tc.value, got, ok, tc.want, tc.ok)
+		}
+	}
+
+	// A random choice still swaps two different neighbouring digits
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 20; i++ {
+		got, _ := alterValue("bank", "123456789012", r)
+		if got == "123456789012" || len(got) != 12 {
+			t.Fatalf("random alteration %q", got)
+		}
+	}
+}
+
+func TestNextReadBackSkipsLinkedValues(t *testing.T) {
+	intel := Intel{Bank: []string{"123456789012"}, UPI: []string{"fraud.desk@ybl"}}
+	rb, ok := NextReadBack(intel, nil)
+	if !ok || rb.Kind != "bank" || rb.Altered != "123456879012" {
+		t.Fatalf("first read-back = %+v, %v, want the bank account", rb, ok)
+	}
+	intel.Links = []IntelLink{{Kind: "bank", Original: "123456789012"}}
+	if rb, _ := NextReadBack(intel, nil); rb.Kind != "upi" {
+		t.Errorf("second read-back = %+v, want the UPI ID", rb)
+	}
+	intel.Links = append(intel.Links, IntelLink{Kind: "upi", Original: "fraud.desk@ybl"})
+	if rb, ok := NextReadBack(intel, nil); ok {
+		t.Errorf("read back %+v after everything was linked", rb)
+	}
+}
+
+func TestResolveReadBack(t *testing.T) {
+	pending := &ReadBack{Kind: "bank", Original: "123456789012", Altered: "123456789102", Turn: 4}
+	for _, tc := range []struct {
+		message  string
+		newBank  []string
+		relation string
+		value    string
+	}{
+		{"No sir, it is 123456789012", []string{"123456789012"}, LinkCorrected, "123456789012"},
+		{"Use 987654321098 instead", []string{"987654321098"}, LinkAlternative, "987654321098"},
+		{"No, that is wrong", nil, LinkRejected, ""},
+		{"Galat hai", nil, LinkRejected, ""},
+		{"Yes, correct", nil, LinkAccepted, ""},
+		{"Yes, account no. is right", nil, LinkAccepted, ""},
+		{"Yes, account no 12345 is right", nil, LinkAccepted, ""},
+		{"Ok no problem, send it", nil, LinkAccepted, ""},
+		{"Yes 123456789102", []string{"123456789102"}, LinkAccepted, ""},
+		{"Send the money quickly", nil, LinkIgnored, ""},
+	} {
+		intel := Intel{Bank: append([]string{"123456789012"}, tc.newBank...)}
+		got := ResolveReadBack(intel, pending, tc.message, Intel{Bank: tc.newBank}, 5)
+		if len(got.Links) != 1 {
+			t.Fatalf("%q: %d links, want 1", tc.message, len(got.Links))
+		}
+		link := got.Links[0]
+		if link.Relation != tc.relation || link.Value != tc.value || link.Turn != 5 || link.ReadBack != pending.Altered {
+			t.Errorf("%q: link %+v, want %s %q", tc.message, link, tc.relation, tc.value)
+		}
+		// Our own mistake never ends up in the intel
+		for _, v := range got.Bank {
+			if v == pending.Altered {
+				t.Errorf("%q: altered value kept in the intel", tc.message)
+			}
+		}
+	}
+
+	if got := ResolveReadBack(Intel{Bank: []string{"1"}}, nil, "no", Intel{}, 5); len(got.Links) != 0 || len(got.Bank) != 1 {
+		t.Errorf("without a pending read-back: %+v", got)
+	}
+}
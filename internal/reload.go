@@ -0,0 +1,47 @@
+package internal
+
+import (
+	"log"
+	"sort"
+	"sync"
+)
+
+// Reloadable configuration (prompts, templates, rules, ...) registers here so
+// a SIGHUP or an admin call can refresh everything without a restart
+var (
+	reloaders   = map[string]func() error{}
+	reloadersMu sync.Mutex
+)
+
+// RegisterReloader adds a named reload function
+func RegisterReloader(name string, fn func() error) {
+	reloadersMu.Lock()
+	defer reloadersMu.Unlock()
+	reloaders[name] = fn
+}
+
+// ReloadAll runs every registered reloader and returns the error of each one
+// that failed. A failed reload keeps the previously loaded configuration.
+func ReloadAll() map[string]error {
+	reloadersMu.Lock()
+	names := make([]string, 0, len(reloaders))
+	for name := range reloaders {
+		names = append(names, name)
+	}
+	reloadersMu.Unlock()
+	sort.Strings(names)
+
+	failed := map[string]error{}
+	for _, name := range names {
+		reloadersMu.Lock()
+		fn := reloaders[name]
+		reloadersMu.Unlock()
+		if err := fn(); err != nil {
+			log.Printf("Reload of %s failed, keeping previous version: %v", name, err)
+			failed[name] = err
+			continue
+		}
+		log.Printf("Reloaded %s", name)
+	}
+	return failed
+}
@@ -0,0 +1,181 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ResponseRequest carries everything a Responder needs to produce the next reply
+type ResponseRequest struct {
+	SessionID      string
+	Intent         Intent
+	ScammerMessage string
+	History        []ChatTurn // Earlier messages in the conversation, oldest first
+	TurnCount      int
+	ScamType       string
+	ClaimedOrg     string
+	Intel          Intel
+	Persona        Persona
+	Facts          *FactLedger        // Earlier claims replies must not contradict
+	Locale         string             // Prompt locale key, "default" when empty
+	InjectionRisk  string             // Result of DetectInjection on the latest message
+	Selection      *TemplateSelection // Templates already used in the session, may be nil
+	Act            DialogueAct        // Primary dialogue act of the latest message
+	Choice         *StrategyChoice    // Receives the template used, may be nil
+	ReadBack       *ReadBack          // Identifier to read back for IntentReadBack
+	PaymentTarget  string             // Account the victim's transfer "failed" to, see PaymentFlow
+	Honeytokens    []Honeytoken       // Fake credentials to hand over for IntentGiveHoneytoken
+	Canary         *Canary            // Link to send for IntentSendCanary, nil when canaries are off
+}
+
+// Responder produces the victim's reply for a single turn
+type Responder interface {
+	Name() string
+	Respond(ctx context.Context, req ResponseRequest) (string, error)
+}
+
+// TemplateResponder picks a canned reply from the response catalogue
+type TemplateResponder struct{}
+
+func (TemplateResponder) Name() string { return "template" }
+
+func (TemplateResponder) Respond(ctx context.Context, req ResponseRequest) (string, error) {
+	data := SlotData{
+		Persona:    req.Persona,
+		ClaimedOrg: req.ClaimedOrg,
+		LastUPI:    lastValue(req.Intel.UPI),
+		LastPhone:  lastValue(req.Intel.Phone),
+		Locale:     req.Locale,
+
+		FailedAccount: req.PaymentTarget,
+	}
+	if rb := req.ReadBack; rb != nil {
+		switch rb.Kind {
+		case "upi":
+			data.ReadBackUPI = rb.Altered
+		case "bank":
+			data.ReadBackAccount = rb.Altered
+		case "phone":
+			data.ReadBackPhone = rb.Altered
+		}
+	}
+	// One value per reply reads naturally, except that a card goes with its CVV
+	for i, t := range req.Honeytokens {
+		if i == 0 || t.Kind == HoneytokenCVV && data.HoneyCard != "" {
+			data.setHoneytoken(t)
+		}
+	}
+	if c := req.Canary; c != nil {
+		switch c.Kind {
+		case CanaryImage:
+			data.CanaryScreenshot = c.URL
+		case CanaryPDF:
+			data.CanaryReceipt = c.URL
+		case CanaryPage:
+			data.CanaryLink = c.URL
+		}
+	}
+	reply, key := selectResponse(req.ScamType, req.Intent, data, func(reply string) bool {
+		return len(req.Facts.Conflicts(reply, req.TurnCount)) == 0
+	}, req.Selection)
+	if req.Choice != nil {
+		req.Choice.Template = key
+	}
+	return reply, nil
+}
+
+// LLMResponder generates a reply through the configured chat completion providers
+type LLMResponder struct {
+	Timeout time.Duration
+}
+
+func (LLMResponder) Name() string { return "llm" }
+
+func (l LLMResponder) Respond(ctx context.Context, req ResponseRequest) (string, error) {
+	if l.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, l.Timeout)
+		defer cancel()
+	}
+	return GenerateLLMResponse(ctx, req)
+}
+
+// ChainResponder tries each responder in order and returns the first successful reply
+type ChainResponder struct {
+	Responders []Responder
+}
+
+func (c ChainResponder) Name() string {
+	names := make([]string, 0, len(c.Responders))
+	for _, r := range c.Responders {
+		names = append(names, r.Name())
+	}
+	return "chain(" + strings.Join(names, ",") + ")"
+}
+
+func (c ChainResponder) Respond(ctx context.Context, req ResponseRequest) (string, error) {
+	var errs []error
+	for _, r := range c.Responders {
+		reply, err := r.Respond(ctx, req)
+		if err == nil && strings.TrimSpace(reply) != "" {
+			return reply, nil
+		}
+		if err == nil {
+			err = errors.New("empty reply")
+		}
+		log.Printf("Responder %s failed for session %s: %v", r.Name(), req.SessionID, err)
+		errs = append(errs, err)
+	}
+	if len(errs) == 0 {
+		return "", errors.New("no responders configured")
+	}
+	return "", errors.Join(errs...)
+}
+
+var (
+	defaultResponder Responder
+	responderOnce    sync.Once
+)
+
+// GetResponder returns the responder selected by the RESPONDER environment variable.
+// It is resolved lazily so that values loaded from .env are picked up.
+func GetResponder() Responder {
+	responderOnce.Do(func() {
+		defaultResponder = NewResponderFromEnv()
+		log.Printf("Using responder: %s", defaultResponder.Name())
+	})
+	return defaultResponder
+}
+
+// NewResponderFromEnv builds a responder from configuration:
+//
+//	RESPONDER   template | llm | chain (default: chain when an LLM provider is usable, template otherwise)
+//	LLM_TIMEOUT per-reply deadline for the LLM responder (default 8s)
+func NewResponderFromEnv() Responder {
+	llm := LLMResponder{Timeout: envDuration("LLM_TIMEOUT", 8*time.Second)}
+
+	mode := strings.ToLower(strings.TrimSpace(os.Getenv("RESPONDER")))
+	if mode == "" {
+		mode = "template"
+		if len(GetProviders()) > 0 {
+			mode = "chain"
+		}
+	}
+
+	switch mode {
+	case "template":
+		return TemplateResponder{}
+	case "llm":
+		return llm
+	case "chain":
+		return ChainResponder{Responders: []Responder{llm, TemplateResponder{}}}
+	default:
+		log.Printf("Unknown RESPONDER %q, using templates", mode)
+		return TemplateResponder{}
+	}
+}
@@ -0,0 +1,107 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// stubResponder answers every request with the same reply and error
+type stubResponder struct {
+	name  string
+	reply string
+	err   error
+	calls int
+}
+
+func (s *stubResponder) Name() string { return s.name }
+
+func (s *stubResponder) Respond(ctx context.Context, req ResponseRequest) (string, error) {
+	s.calls++
+	return s.reply, s.err
+}
+
+// useProviders replaces the configured LLM providers for the duration of the test
+func useProviders(t *testing.T, list []Provider) {
+	t.Helper()
+	GetProviders()
+	saved := providers
+	providers = list
+	t.Cleanup(func() { providers = saved })
+}
+
+func templateRequest(intent Intent) ResponseRequest {
+	return ResponseRequest{
+		SessionID:      "test-session",
+		Intent:         intent,
+		ScammerMessage: "Your account will be blocked today, share your UPI ID",
+		TurnCount:      2,
+		ScamType:       "bank_fraud",
+		Persona:        DefaultPersona,
+		Facts:          &FactLedger{},
+	}
+}
+
+func TestChainResponderFallsBackToTemplates(t *testing.T) {
+	failing := &stubResponder{name: "llm", err: errors.New("provider down")}
+	chain := ChainResponder{Responders: []Responder{failing, TemplateResponder{}}}
+
+	reply, err := chain.Respond(context.Background(), templateRequest(IntentAskUPI))
+	if err != nil {
+		t.Fatalf("Respond: %v", err)
+	}
+	if strings.TrimSpace(reply) == "" {
+		t.Fatal("empty reply from the template fallback")
+	}
+	if failing.calls != 1 {
+		t.Errorf("failing responder called %d times, want 1", failing.calls)
+	}
+}
+
+func TestChainResponderSkipsEmptyReplies(t *testing.T) {
+	empty := &stubResponder{name: "empty", reply: "  "}
+	next := &stubResponder{name: "next", reply: "Which branch is this?"}
+	chain := ChainResponder{Responders: []Responder{empty, next}}
+
+	reply, err := chain.Respond(context.Background(), templateRequest(IntentStall))
+	if err != nil {
+		t.Fatalf("Respond: %v", err)
+	}
+	if reply != next.reply {
+		t.Errorf("reply = %q, want %q", reply, next.reply)
+	}
+}
+
+func TestChainResponderReportsEveryFailure(t *testing.T) {
+	first := errors.New("first failed")
+	second := errors.New("second failed")
+	chain := ChainResponder{Responders: []Responder{
+		&stubResponder{name: "a", err: first},
+		&stubResponder{name: "b", err: second},
+	}}
+
+	_, err := chain.Respond(context.Background(), templateRequest(IntentStall))
+	if !errors.Is(err, first) || !errors.Is(err, second) {
+		t.Errorf("error %v does not wrap both failures", err)
+	}
+	if _, err := (ChainResponder{}).Respond(context.Background(), templateRequest(IntentStall)); err == nil {
+		t.Error("empty chain returned no error")
+	}
+}
+
+func TestChainResponderName(t *testing.T) {
+	chain := ChainResponder{Responders: []Responder{LLMResponder{}, TemplateResponder{}}}
+	if got := chain.Name(); got != "chain(llm,template)" {
+		t.Errorf("Name() = %q", got)
+	}
+}
+
+func TestLLMResponderWithoutProviders(t *testing.T) {
+	useProviders(t, nil)
+
+	_, err := LLMResponder{}.Respond(context.Background(), templateRequest(IntentStall))
+	if !errors.Is(err, ErrLLMUnavailable) {
+		t.Errorf("error = %v, want ErrLLMUnavailable", err)
+	}
+}
@@ -0,0 +1,161 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// coveringTemplates returns one unconditional English template per intent,
+// the least buildCatalogue accepts
+func coveringTemplates() map[string]map[string]ResponseTemplate {
+	en := map[string]ResponseTemplate{}
+	for _, intent := range AllIntents {
+		id := strings.ToLower(string(intent))
+		en[id] = ResponseTemplate{ID: id, Intent: intent, Text: "What do you mean by that?"}
+	}
+	return map[string]map[string]ResponseTemplate{LocaleEnglish: en}
+}
+
+func TestEmbeddedCatalogueCoversEveryIntent(t *testing.T) {
+	t.Setenv("RESPONSE_CATALOGUE_DIR", "")
+	c := &ResponseCatalogue{}
+	if err := c.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	for _, intent := range AllIntents {
+		for _, locale := range []string{LocaleEnglish, LocaleHindi, LocaleHinglish, LocaleTamil, LocaleBengali} {
+			templates, _ := c.candidates(intent, SlotData{Persona: DefaultPersona, Locale: locale})
+			if len(templates) == 0 {
+				t.Errorf("no %s template for %s", locale, intent)
+			}
+		}
+	}
+}
+
+func TestBuildCatalogueRequiresUnconditionalEnglish(t *testing.T) {
+	byID := coveringTemplates()
+	if _, err := buildCatalogue(byID); err != nil {
+		t.Fatalf("covering catalogue rejected: %v", err)
+	}
+
+	// A template limited to a persona or waiting for a slot does not cover its intent
+	stall := strings.ToLower(string(IntentStall))
+	byID[LocaleEnglish][stall] = ResponseTemplate{ID: stall, Intent: IntentStall, Text: "Wait.", Personas: []string{"retired_teacher"}}
+	upi := strings.ToLower(string(IntentAskUPI))
+	byID[LocaleEnglish][upi] = ResponseTemplate{ID: upi, Intent: IntentAskUPI, Text: "Is it {{.LastUPI}}?", Requires: []string{"LastUPI"}}
+	_, err := buildCatalogue(byID)
+	if err == nil {
+		t.Fatal("catalogue without unconditional STALL and ASK_UPI templates accepted")
+	}
+	for _, intent := range []Intent{IntentStall, IntentAskUPI} {
+		if !strings.Contains(err.Error(), "no unconditional template for intent "+string(intent)) {
+			t.Errorf("error %q does not name %s", err, intent)
+		}
+	}
+
+	// Other locales need not cover every intent
+	byID = coveringTemplates()
+	byID[LocaleHindi] = map[string]ResponseTemplate{"stall": {ID: "stall", Intent: IntentStall, Text: "रुकिए।"}}
+	if _, err := buildCatalogue(byID); err != nil {
+		t.Errorf("partial Hindi pack rejected: %v", err)
+	}
+}
+
+func TestBuildCatalogueRejectsInvalidTemplates(t *testing.T) {
+	for name, tmpl := range map[string]ResponseTemplate{
+		"unknown intent":  {Intent: "DANCE", Text: "Hello?"},
+		"empty text":      {Intent: IntentStall, Text: "  "},
+		"negative weight": {Intent: IntentStall, Text: "Hello?", Weight: -1},
+		"unknown persona": {Intent: IntentStall, Text: "Hello?", Personas: []string{"astronaut"}},
+		"unknown slot":    {Intent: IntentStall, Text: "Hello?", Requires: []string{"Nickname"}},
+		"bad placeholder": {Intent: IntentStall, Text: "Hello {{.Nickname}}?"},
+		"bad syntax":      {Intent: IntentStall, Text: "Hello {{.Name?"},
+		"bad paraphrase":  {Intent: IntentStall, Text: "Hello?", Paraphrases: []string{"Hi {{.Nickname}}?"}},
+	} {
+		byID := coveringTemplates()
+		tmpl.ID = "broken"
+		byID[LocaleEnglish]["broken"] = tmpl
+		if _, err := buildCatalogue(byID); err == nil || !strings.Contains(err.Error(), "en/broken") {
+			t.Errorf("%s: error = %v, want one naming en/broken", name, err)
+		}
+	}
+}
+
+func TestCandidatesFilterAndFallBack(t *testing.T) {
+	byID := coveringTemplates()
+	byID[LocaleEnglish]["upi_known"] = ResponseTemplate{ID: "upi_known", Intent: IntentAskUPI, Text: "Is it {{.LastUPI}}?", Requires: []string{"LastUPI"}}
+	byID[LocaleEnglish]["upi_teacher"] = ResponseTemplate{ID: "upi_teacher", Intent: IntentAskUPI, Text: "Spell it for me?", Personas: []string{"retired_teacher"}}
+	byID[LocaleHindi] = map[string]ResponseTemplate{"stall": {ID: "stall", Intent: IntentStall, Text: "रुकिए।"}}
+	templates, err := buildCatalogue(byID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &ResponseCatalogue{templates: templates}
+
+	ids := func(intent Intent, data SlotData) []string {
+		var ids []string
+		list, _ := c.candidates(intent, data)
+		for _, t := range list {
+			ids = append(ids, t.key())
+		}
+		return ids
+	}
+	other, _ := GetPersona("retired_clerk")
+	teacher, _ := GetPersona("retired_teacher")
+	for _, tc := range []struct {
+		intent Intent
+		data   SlotData
+		want   string
+	}{
+		{IntentAskUPI, SlotData{Persona: other}, "en/ask_upi"},
+		{IntentAskUPI, SlotData{Persona: other, LastUPI: "x@ybl"}, "en/ask_upi en/upi_known"},
+		{IntentAskUPI, SlotData{Persona: teacher}, "en/ask_upi en/upi_teacher"},
+		{IntentStall, SlotData{Persona: other, Locale: LocaleHindi}, "hi/stall"},
+		{IntentStall, SlotData{Persona: other, Locale: LocaleHinglish}, "hi/stall"},
+		{IntentStall, SlotData{Persona: other, Locale: LocaleTamil}, "en/stall"},
+		{IntentAskUPI, SlotData{Persona: other, Locale: LocaleHindi}, "en/ask_upi"},
+	} {
+		if got := strings.Join(ids(tc.intent, tc.data), " "); got != tc.want {
+			t.Errorf("%s for %s in %q: got %q, want %q", tc.intent, tc.data.Persona.ID, tc.data.Locale, got, tc.want)
+		}
+	}
+}
+
+func TestCatalogueOverrideDirectory(t *testing.T) {
+	dir := t.TempDir()
+	override := `{"locale": "en", "responses": [{"id": "confirm_details_01", "intent": "CONFIRM_DETAILS", "text": "Overridden, what happened?"}]}`
+	if err := os.WriteFile(filepath.Join(dir, "en.json"), []byte(override), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("RESPONSE_CATALOGUE_DIR", dir)
+	c := &ResponseCatalogue{}
+	if err := c.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	templates, _ := c.candidates(IntentConfirmDetails, SlotData{Persona: DefaultPersona})
+	found := 0
+	for _, tmpl := range templates {
+		if tmpl.ID == "confirm_details_01" {
+			found++
+			if tmpl.Text != "Overridden, what happened?" {
+				t.Errorf("confirm_details_01 = %q, want the override", tmpl.Text)
+			}
+		}
+	}
+	if found != 1 || len(templates) < 2 {
+		t.Errorf("override should replace one of %d templates, found it %d times", len(templates), found)
+	}
+
+	// A broken override is rejected and the working catalogue kept
+	if err := os.WriteFile(filepath.Join(dir, "en.json"), []byte(`{"locale": "en", "replace": true, "responses": []}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Reload(); err == nil {
+		t.Fatal("replacing English with nothing was accepted")
+	}
+	if templates, _ := c.candidates(IntentConfirmDetails, SlotData{Persona: DefaultPersona}); len(templates) == 0 {
+		t.Error("failed reload dropped the catalogue")
+	}
+}
@@ -0,0 +1,386 @@
+package internal
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// The scam detection rules live in rules/default.json, or in the file named by
+// DETECTION_RULES_FILE. Each rule is a pattern with a weight and the indicator
+// flags it sets; combinations decide when the flags and the total score add
+// up to a scam. Rules and combinations carry example messages that are checked
+// whenever the file is loaded, so an edited pattern cannot silently stop
+// matching what it was written for. Examples of combinations that need the
+// classifier's flag are scored with the embedded model as well.
+//
+//go:embed rules/default.json
+var embeddedRules []byte
+
+// DetectionRule scores one kind of scam language
+type DetectionRule struct {
+	ID       string       `json:"id"`
+	Pattern  string       `json:"pattern"` // Go regexp; the first match is reported as a keyword
+	Weight   int          `json:"weight"`
+	Flags    []string     `json:"flags,omitempty"` // Indicator flags set when the pattern matches
+	Examples RuleExamples `json:"examples"`
+
+	re *regexp.Regexp
+}
+
+// RuleExamples are messages a rule or combination must and must not fire on
+type RuleExamples struct {
+	Match   []string `json:"match"`
+	NoMatch []string `json:"noMatch"`
+}
+
+// Combination declares a message a scam when every clause has at least one of
+// its flags set and the score reaches MinScore
+type Combination struct {
+	ID          string       `json:"id"`
+	Description string       `json:"description,omitempty"`
+	All         [][]string   `json:"all,omitempty"` // Clauses ANDed together, the flags of a clause ORed
+	MinScore    int          `json:"minScore"`
+	Examples    RuleExamples `json:"examples,omitempty"`
+}
+
+// SessionScoring configures how the evidence of a session's messages adds up,
+// see SessionScore
+type SessionScoring struct {
+	Decay        float64         `json:"decay"`        // Factor applied to the score before each message, 1 keeps everything
+	RepeatWeight float64         `json:"repeatWeight"` // Factor applied to a rule's weight each time it fires again, 0 counts it once
+	HalfScore    float64         `json:"halfScore"`    // Session score at which the confidence is 0.5
+	Examples     SessionExamples `json:"examples"`
+}
+
+// SessionExamples are conversations, scammer messages only, that must and must
+// not add up to a scam
+type SessionExamples struct {
+	Match   [][]string `json:"match"`
+	NoMatch [][]string `json:"noMatch"`
+}
+
+// ClassifierBlend configures how the classifier's probability adds to the rule
+// score of a message, see ClassifierModel
+type ClassifierBlend struct {
+	Weight int     `json:"weight"`         // Points added at probability 1 and taken away at 0, none at 0.5
+	Flag   string  `json:"flag,omitempty"` // Indicator flag set from probability FlagAt
+	FlagAt float64 `json:"flagAt,omitempty"`
+}
+
+// RuleSet is a parsed detection rule file
+type RuleSet struct {
+	Version      int             `json:"version"`
+	Rules        []DetectionRule `json:"rules"`
+	Combinations []Combination   `json:"combinations"` // Tried in order, the first that holds decides
+	Session      SessionScoring  `json:"session"`
+	Classifier   ClassifierBlend `json:"classifier"`
+
+	byID map[string]*DetectionRule
+}
+
+var (
+	rulesMu      sync.RWMutex
+	currentRules *RuleSet
+	rulesOnce    sync.Once
+	rulesErr     error
+)
+
+// LoadRules loads and validates the detection rules and registers them for
+// reloading. It is called at startup so that broken rules stop the server.
+func LoadRules() error {
+	rulesOnce.Do(func() {
+		rulesErr = ReloadRules()
+		RegisterReloader("detection rules", ReloadRules)
+	})
+	return rulesErr
+}
+
+// GetRules returns the current detection rules, loading them on first use
+func GetRules() *RuleSet {
+	if err := LoadRules(); err != nil {
+		log.Printf("Detection rules failed to load: %v", err)
+	}
+	rulesMu.RLock()
+	defer rulesMu.RUnlock()
+	return currentRules
+}
+
+// ReloadRules reads the embedded rules or DETECTION_RULES_FILE and swaps them
+// in only if they validate and every example is classified as declared
+func ReloadRules() error {
+	data, source := embeddedRules, "embedded rules"
+	if path := os.Getenv("DETECTION_RULES_FILE"); path != "" {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return err
+		}
+		source = path
+	}
+	set, err := ParseRules(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", source, err)
+	}
+
+	rulesMu.Lock()
+	currentRules = set
+	rulesMu.Unlock()
+	log.Printf("Loaded detection rules v%d: %d rules, %d combinations", set.Version, len(set.Rules), len(set.Combinations))
+	return nil
+}
+
+// ParseRules parses and validates a rule file, including its examples
+func ParseRules(data []byte) (*RuleSet, error) {
+	var set RuleSet
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+	if err := set.validate(); err != nil {
+		return nil, err
+	}
+	if err := set.checkExamples(); err != nil {
+		return nil, err
+	}
+	return &set, nil
+}
+
+func (rs *RuleSet) validate() error {
+	var errs []error
+	if rs.Version <= 0 {
+		errs = append(errs, errors.New("version must be positive"))
+	}
+	if len(rs.Rules) == 0 {
+		errs = append(errs, errors.New("no rules"))
+	}
+	ids := map[string]bool{}
+	flags := map[string]bool{}
+	rs.byID = map[string]*DetectionRule{}
+	for i := range rs.Rules {
+		r := &rs.Rules[i]
+		if r.ID == "" {
+			errs = append(errs, fmt.Errorf("rule %d has no id", i+1))
+		} else if ids[r.ID] {
+			errs = append(errs, fmt.Errorf("duplicate rule %q", r.ID))
+		}
+		ids[r.ID] = true
+		rs.byID[r.ID] = r
+		re, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("rule %q: %w", r.ID, err))
+		}
+		r.re = re
+		if len(r.Examples.Match) == 0 || len(r.Examples.NoMatch) == 0 {
+			errs = append(errs, fmt.Errorf("rule %q needs examples it matches and does not match", r.ID))
+		}
+		for _, f := range r.Flags {
+			flags[f] = true
+		}
+	}
+
+	if rs.Classifier.Weight < 0 {
+		errs = append(errs, errors.New("classifier weight must not be negative"))
+	}
+	if rs.Classifier.Flag != "" {
+		if rs.Classifier.FlagAt <= 0.5 || rs.Classifier.FlagAt > 1 {
+			errs = append(errs, errors.New("classifier flagAt must be in (0.5, 1]"))
+		}
+		flags[rs.Classifier.Flag] = true
+	}
+
+	if len(rs.Combinations) == 0 {
+		errs = append(errs, errors.New("no combinations"))
+	}
+	for i, c := range rs.Combinations {
+		if c.ID == "" {
+			errs = append(errs, fmt.Errorf("combination %d has no id", i+1))
+		} else if ids[c.ID] {
+			errs = append(errs, fmt.Errorf("duplicate id %q", c.ID))
+		}
+		ids[c.ID] = true
+		if c.MinScore < 0 {
+			errs = append(errs, fmt.Errorf("combination %q: negative minScore", c.ID))
+		}
+		if len(c.All) == 0 && c.MinScore == 0 {
+			errs = append(errs, fmt.Errorf("combination %q fires on every message", c.ID))
+		}
+		if len(c.Examples.Match) == 0 || len(c.Examples.NoMatch) == 0 {
+			errs = append(errs, fmt.Errorf("combination %q needs examples it holds and does not hold for", c.ID))
+		}
+		for _, clause := range c.All {
+			if len(clause) == 0 {
+				errs = append(errs, fmt.Errorf("combination %q has an empty clause", c.ID))
+			}
+			for _, f := range clause {
+				if !flags[f] {
+					errs = append(errs, fmt.Errorf("combination %q: no rule sets flag %q", c.ID, f))
+				}
+			}
+		}
+	}
+
+	if rs.Session.Decay <= 0 || rs.Session.Decay > 1 {
+		errs = append(errs, errors.New("session decay must be in (0, 1]"))
+	}
+	if rs.Session.RepeatWeight < 0 || rs.Session.RepeatWeight > 1 {
+		errs = append(errs, errors.New("session repeatWeight must be in [0, 1]"))
+	}
+	if rs.Session.HalfScore <= 0 {
+		errs = append(errs, errors.New("session halfScore must be positive"))
+	}
+	return errors.Join(errs...)
+}
+
+// checkExamples runs every example through the rules. The examples of a
+// combination needing the classifier flag are blended with the embedded model,
+// whatever CLASSIFIER_MODEL says, since the classifier loads after the rules.
+func (rs *RuleSet) checkExamples() error {
+	var errs []error
+	for _, r := range rs.Rules {
+		for _, msg := range r.Examples.Match {
+			if !r.re.MatchString(msg) {
+				errs = append(errs, fmt.Errorf("rule %q does not match %q", r.ID, msg))
+			}
+		}
+		for _, msg := range r.Examples.NoMatch {
+			if r.re.MatchString(msg) {
+				errs = append(errs, fmt.Errorf("rule %q matches %q", r.ID, msg))
+			}
+		}
+	}
+	var model *ClassifierModel
+	for _, c := range rs.Combinations {
+		blend := rs.Classifier.Flag != "" && c.uses(rs.Classifier.Flag)
+		if blend && model == nil {
+			m, err := ParseClassifier(embeddedModel)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("combination %q: embedded classifier: %w", c.ID, err))
+				continue
+			}
+			model = m
+		}
+		for _, want := range []bool{true, false} {
+			examples := c.Examples.Match
+			if !want {
+				examples = c.Examples.NoMatch
+			}
+			for _, msg := range examples {
+				var ind ScamIndicators
+				rs.Detect(msg, &ind)
+				if blend {
+					rs.Blend(msg, model, &ind)
+				}
+				if c.holds(&ind) != want {
+					errs = append(errs, fmt.Errorf("combination %q on %q (score %d, flags %v): got %v, want %v",
+						c.ID, msg, ind.Score, ind.flagList(), !want, want))
+				}
+			}
+		}
+	}
+	for _, want := range []bool{true, false} {
+		conversations := rs.Session.Examples.Match
+		if !want {
+			conversations = rs.Session.Examples.NoMatch
+		}
+		for _, messages := range conversations {
+			var score SessionScore
+			for _, msg := range messages {
+				var ind ScamIndicators
+				rs.Detect(msg, &ind)
+				score.Add(&ind)
+			}
+			if ind := score.indicators(); score.Detected != want {
+				errs = append(errs, fmt.Errorf("session %q (score %.1f, flags %v): got %v, want %v",
+					strings.Join(messages, " / "), score.Score, ind.flagList(), !want, want))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Detect adds the weight, keyword, hit and flags of every rule matching the input
+func (rs *RuleSet) Detect(input string, indicators *ScamIndicators) {
+	indicators.RulesVersion = rs.Version
+	indicators.rules = rs
+	for _, r := range rs.Rules {
+		loc := r.re.FindStringIndex(input)
+		if loc == nil || loc[0] == loc[1] {
+			continue
+		}
+		m := input[loc[0]:loc[1]]
+		indicators.Score += r.Weight
+		indicators.Words = append(indicators.Words, m)
+		indicators.Hits = append(indicators.Hits, RuleHit{Rule: r.ID, Text: m, Start: loc[0], End: loc[1], Weight: r.Weight})
+		for _, f := range r.Flags {
+			if indicators.Flags == nil {
+				indicators.Flags = map[string]bool{}
+			}
+			indicators.Flags[f] = true
+		}
+	}
+}
+
+// Blend adds the classifier's opinion of the input to the indicators
+func (rs *RuleSet) Blend(input string, model *ClassifierModel, indicators *ScamIndicators) {
+	if model == nil {
+		return
+	}
+	p := model.Probability(input)
+	indicators.ModelVersion = model.Version
+	indicators.ModelProbability = p
+	indicators.ModelPoints = int(math.Round(float64(rs.Classifier.Weight) * (2*p - 1)))
+	indicators.Score += indicators.ModelPoints
+	if rs.Classifier.Flag != "" && p >= rs.Classifier.FlagAt {
+		if indicators.Flags == nil {
+			indicators.Flags = map[string]bool{}
+		}
+		indicators.Flags[rs.Classifier.Flag] = true
+	}
+}
+
+// Decide returns the first combination that holds, nil when none does
+func (rs *RuleSet) Decide(indicators *ScamIndicators) *Combination {
+	for i := range rs.Combinations {
+		if rs.Combinations[i].holds(indicators) {
+			return &rs.Combinations[i]
+		}
+	}
+	return nil
+}
+
+// uses reports whether a clause of the combination accepts the flag
+func (c *Combination) uses(flag string) bool {
+	for _, clause := range c.All {
+		for _, f := range clause {
+			if f == flag {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (c *Combination) holds(indicators *ScamIndicators) bool {
+	if indicators.Score < c.MinScore {
+		return false
+	}
+	for _, clause := range c.All {
+		ok := false
+		for _, f := range clause {
+			if indicators.Flags[f] {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
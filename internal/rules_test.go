@@ -0,0 +1,42 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEmbeddedRulesValid(t *testing.T) {
+	if _, err := ParseRules(embeddedRules); err != nil {
+		t.Fatalf("embedded rules: %v", err)
+	}
+}
+
+func TestParseRulesChecksCombinationExamples(t *testing.T) {
+	valid := string(embeddedRules)
+	confident := `"match": ["Sir your electricity connection will be disconnected tonight, call this officer"],
+        "noMatch": ["Please share the meeting notes from today"]`
+	for name, tc := range map[string]struct {
+		old, new string
+		want     string
+	}{
+		"combination without examples": {confident, `"match": [], "noMatch": []`, `combination "classifier_confident" needs examples`},
+		"classifier example that does not hold": {
+			`"match": ["Sir your electricity connection will be disconnected tonight, call this officer"]`,
+			`"match": ["Happy birthday, see you at dinner tonight"]`,
+			`combination "classifier_confident" on "Happy birthday, see you at dinner tonight"`,
+		},
+		"classifier example that holds": {
+			`"noMatch": ["Please share the meeting notes from today"]`,
+			`"noMatch": ["Sir your electricity connection will be disconnected tonight, call this officer"]`,
+			`combination "classifier_confident" on "Sir your electricity connection`,
+		},
+	} {
+		if !strings.Contains(valid, tc.old) {
+			t.Fatalf("%s: %q not in the embedded rules", name, tc.old)
+		}
+		_, err := ParseRules([]byte(strings.Replace(valid, tc.old, tc.new, 1)))
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: error = %v, want one containing %q", name, err, tc.want)
+		}
+	}
+}
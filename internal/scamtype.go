@@ -0,0 +1,80 @@
+package internal
+
+import (
+	"strings"
+)
+
+// DetermineScamType classifies the session into a scam category based on the
+// suspicious keywords, the full message history and the captured intel
+func DetermineScamType(session *SessionData) string {
+	// Scan ALL text: keywords + full conversation history
+	allText := strings.ToLower(strings.Join(session.Keywords, " ") + " " + strings.Join(session.MessageHistory, " "))
+
+	// Digital arrest: a fake officer keeps the victim under "arrest" on a video call
+	if strings.Contains(allText, "digital arrest") ||
+		((strings.Contains(allText, "skype") || strings.Contains(allText, "video call")) &&
+			(strings.Contains(allText, "police") || strings.Contains(allText, "cbi") ||
+				strings.Contains(allText, "arrest") || strings.Contains(allText, "narcotics") ||
+				strings.Contains(allText, "customs") || strings.Contains(allText, "money laundering"))) {
+		return "digital_arrest"
+	}
+
+	// Government/legal threat (very distinct pattern)
+	if strings.Contains(allText, "police") || strings.Contains(allText, "arrest") ||
+		strings.Contains(allText, "cbi") || strings.Contains(allText, "warrant") ||
+		strings.Contains(allText, "court") || strings.Contains(allText, "legal action") {
+		return "govt_threat_fraud"
+	}
+
+	// Tech support fraud
+	if strings.Contains(allText, "virus") || strings.Contains(allText, "malware") ||
+		strings.Contains(allText, "hacked") || strings.Contains(allText, "remote access") ||
+		strings.Contains(allText, "technical support") {
+		return "tech_support_fraud"
+	}
+
+	// Lottery/prize/cashback fraud
+	if strings.Contains(allText, "prize") || strings.Contains(allText, "lottery") ||
+		strings.Contains(allText, "winner") || strings.Contains(allText, "cashback") ||
+		strings.Contains(allText, "reward") || strings.Contains(allText, "refund") {
+		return "lottery_fraud"
+	}
+
+	// Delivery/parcel/customs fraud
+	if strings.Contains(allText, "parcel") || strings.Contains(allText, "customs") ||
+		strings.Contains(allText, "package") || strings.Contains(allText, "courier") {
+		return "delivery_fraud"
+	}
+
+	// Check for bank fraud indicators
+	if strings.Contains(allText, "bank") || strings.Contains(allText, "account") ||
+		strings.Contains(allText, "blocked") || strings.Contains(allText, "suspended") ||
+		strings.Contains(allText, "otp") {
+		return "bank_fraud"
+	}
+
+	// Check for UPI fraud indicators
+	if strings.Contains(allText, "upi") || strings.Contains(allText, "payment") ||
+		strings.Contains(allText, "verify") || strings.Contains(allText, "kyc") {
+		return "upi_fraud"
+	}
+
+	// Check for phishing indicators
+	if len(session.Context.Intel.Link) > 0 || strings.Contains(allText, "click") ||
+		strings.Contains(allText, "link") {
+		return "phishing"
+	}
+
+	// Check for impersonation
+	if strings.Contains(allText, "customer care") || strings.Contains(allText, "support team") ||
+		strings.Contains(allText, "rbi") {
+		return "impersonation_fraud"
+	}
+
+	// Default to generic scam if detected
+	if session.Context.ScamDetected {
+		return "generic_scam"
+	}
+
+	return "unknown"
+}
@@ -0,0 +1,126 @@
+package internal
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rng is the shared source for choices made outside a session. Sessions use
+// their own source so concurrent conversations never share random state.
+var rng = rand.New(&lockedSource{src: rand.NewSource(time.Now().UnixNano()).(rand.Source64)})
+
+// lockedSource makes a rand.Source safe for concurrent use
+type lockedSource struct {
+	mu  sync.Mutex
+	src rand.Source64
+}
+
+func (s *lockedSource) Int63() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.src.Int63()
+}
+
+func (s *lockedSource) Uint64() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.src.Uint64()
+}
+
+func (s *lockedSource) Seed(seed int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.src.Seed(seed)
+}
+
+// TemplateSelection remembers which reply templates a session has used so
+// the same wording is not sent twice. It belongs to one session and, like the
+// rest of SessionData, must only be used between BeginTurn and EndTurn.
+type TemplateSelection struct {
+	Used map[string]int // Template key (locale/id) -> times used
+	Last string         // Key of the most recent template
+	Rand *rand.Rand     // Source for this session's choices, nil for the shared one
+}
+
+// NewTemplateSelection returns a selection whose choices are fully determined
+// by seed, so a session can be replayed
+func NewTemplateSelection(seed int64) TemplateSelection {
+	return TemplateSelection{
+		Used: map[string]int{},
+		Rand: rand.New(rand.NewSource(seed)),
+	}
+}
+
+// SessionSeed derives a stable selection seed from a session ID and
+// RESPONSE_SEED_SALT, so replaying a session with the same salt reproduces its
+// replies while a new salt reshuffles every session
+func SessionSeed(sessionID string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(os.Getenv("RESPONSE_SEED_SALT")))
+	h.Write([]byte{0})
+	h.Write([]byte(sessionID))
+	return int64(h.Sum64())
+}
+
+func (s *TemplateSelection) rand() *rand.Rand {
+	if s == nil || s.Rand == nil {
+		return rng
+	}
+	return s.Rand
+}
+
+func (s *TemplateSelection) uses(key string) int {
+	if s == nil {
+		return 0
+	}
+	return s.Used[key]
+}
+
+func (s *TemplateSelection) record(key string) {
+	if s == nil {
+		return
+	}
+	if s.Used == nil {
+		s.Used = map[string]int{}
+	}
+	s.Used[key]++
+	s.Last = key
+}
+
+// pick orders the templates for this turn. Unused templates come first,
+// ranked by learned intel yield for the scam type and their weights; once the
+// pool is exhausted the least used ones follow, never starting with the
+// template sent last turn.
+func (s *TemplateSelection) pick(templates []*ResponseTemplate, scamType string) []*ResponseTemplate {
+	order := GetStrategy().orderTemplates(scamType, templates, s.rand())
+	sort.SliceStable(order, func(i, j int) bool {
+		return s.uses(order[i].key()) < s.uses(order[j].key())
+	})
+	if len(order) > 1 && s != nil && order[0].key() == s.Last {
+		order = append(order[1:], order[0])
+	}
+	return order
+}
+
+// variant renders the template for its next use: the template itself the
+// first time, then its paraphrases, then the text behind one of the locale's
+// openers ("Sorry, I am asking again.")
+func (t *ResponseTemplate) variant(uses int, openers []string, data SlotData) string {
+	if uses == 0 {
+		return t.render(data)
+	}
+	if uses-1 < len(t.paraphrases) {
+		return renderTemplate(t.ID, t.paraphrases[uses-1], data, t.Text)
+	}
+	reply := t.render(data)
+	if len(openers) == 0 {
+		return reply
+	}
+	opener := openers[(uses-1-len(t.paraphrases))%len(openers)]
+	return strings.TrimSpace(opener) + " " + reply
+}
@@ -0,0 +1,104 @@
+package internal
+
+import (
+	"testing"
+	"text/template"
+)
+
+// replay sends the intents through a fresh selection seeded with seed and
+// returns the replies
+func replay(seed int64, intents []Intent) []string {
+	sel := NewTemplateSelection(seed)
+	replies := make([]string, 0, len(intents))
+	for _, intent := range intents {
+		reply, _ := selectResponse("", intent, SlotData{Persona: DefaultPersona}, nil, &sel)
+		replies = append(replies, reply)
+	}
+	return replies
+}
+
+func TestSameSeedReplaysSameReplies(t *testing.T) {
+	intents := []Intent{IntentConfirmDetails, IntentAskUPI, IntentStall, IntentAskUPI, IntentStall, IntentStall, IntentAskPhone, IntentConfirmDetails}
+	seed := SessionSeed("replay-session")
+
+	first := replay(seed, intents)
+	for run := 0; run < 3; run++ {
+		again := replay(seed, intents)
+		for i := range first {
+			if again[i] != first[i] {
+				t.Fatalf("run %d turn %d: %q, first run had %q", run+2, i+1, again[i], first[i])
+			}
+		}
+	}
+
+	differs := false
+	for seed := int64(1); seed <= 5 && !differs; seed++ {
+		other := replay(seed, intents)
+		for i := range first {
+			if other[i] != first[i] {
+				differs = true
+				break
+			}
+		}
+	}
+	if !differs {
+		t.Error("five other seeds all produced the same replies")
+	}
+}
+
+func TestSessionSeed(t *testing.T) {
+	t.Setenv("RESPONSE_SEED_SALT", "")
+	a := SessionSeed("session-a")
+	if SessionSeed("session-a") != a {
+		t.Error("seed of the same session changed")
+	}
+	if SessionSeed("session-b") == a {
+		t.Error("two sessions got the same seed")
+	}
+	t.Setenv("RESPONSE_SEED_SALT", "deploy-2")
+	if SessionSeed("session-a") == a {
+		t.Error("salt did not change the seed")
+	}
+}
+
+func TestSelectionUsesEveryTemplateBeforeRepeating(t *testing.T) {
+	templates, _ := GetResponseCatalogue().candidates(IntentStall, SlotData{Persona: DefaultPersona})
+	if len(templates) < 2 {
+		t.Skipf("only %d STALL templates", len(templates))
+	}
+	sel := NewTemplateSelection(42)
+	seen := map[string]bool{}
+	for i := range templates {
+		_, key := selectResponse("", IntentStall, SlotData{Persona: DefaultPersona}, nil, &sel)
+		if seen[key] {
+			t.Fatalf("turn %d repeated %s before the other %d templates were used", i+1, key, len(templates)-len(seen))
+		}
+		seen[key] = true
+	}
+	last := sel.Last
+	if _, key := selectResponse("", IntentStall, SlotData{Persona: DefaultPersona}, nil, &sel); key == last {
+		t.Errorf("reused %s on two turns in a row", key)
+	}
+}
+
+func TestVariantRewordsRepeats(t *testing.T) {
+	parse := func(text string) *template.Template { return template.Must(template.New("").Parse(text)) }
+	tmpl := &ResponseTemplate{
+		ID:          "t",
+		Text:        "Who is this?",
+		tmpl:        parse("Who is this?"),
+		paraphrases: []*template.Template{parse("Sorry, who is calling?")},
+	}
+	openers := []string{"Sorry, I am asking again.", "Please bear with me."}
+	for uses, want := range []string{
+		"Who is this?",
+		"Sorry, who is calling?",
+		"Sorry, I am asking again. Who is this?",
+		"Please bear with me. Who is this?",
+		"Sorry, I am asking again. Who is this?",
+	} {
+		if got := tmpl.variant(uses, openers, SlotData{}); got != want {
+			t.Errorf("use %d: %q, want %q", uses, got, want)
+		}
+	}
+}
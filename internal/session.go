@@ -9,20 +9,33 @@ import (
 type SessionData struct {
 	SessionID      string
 	Context        SessionContext
-	MessageHistory []string
-	Keywords       []string // Suspicious keywords from ScamDetection
+	MessageHistory []string          // Scammer messages only, used for detection and reporting
+	Transcript     []ChatTurn        // Both sides of the conversation in order
+	Facts          FactLedger        // Claims the agent has made about itself
+	Selection      TemplateSelection // Reply templates already sent in this session
+	Keywords       []string          // Suspicious keywords from ScamDetection
+	Persona        Persona           // Victim persona played for the whole session
+	Locale         string            // Language of the latest reply, see ResolveLocale
 	LastUpdated    time.Time
 	StartTime      time.Time // Track when conversation started for engagement duration
+
+	turnMu *sync.Mutex // Serialises turns of the same session, see BeginTurn
 }
 
 // SessionStore manages all active sessions
 type SessionStore struct {
 	sessions map[string]*SessionData
+	finished map[string]time.Time // Sessions closed after their final callback, by closing time
 	mu       sync.RWMutex
 }
 
+// finishedTTL is how long a closed session is refused. It covers late retries
+// of the final turn; after it the ID may start a new session.
+const finishedTTL = time.Hour
+
 var globalStore = &SessionStore{
 	sessions: make(map[string]*SessionData),
+	finished: make(map[string]time.Time),
 }
 
 // GetStore returns the global session store
@@ -40,6 +53,7 @@ func (s *SessionStore) Get(sessionID string) *SessionData {
 	}
 
 	// Create new session
+	persona := SelectPersona(sessionID)
 	newSession := &SessionData{
 		SessionID: sessionID,
 		Context: SessionContext{
@@ -56,17 +70,25 @@ func (s *SessionStore) Get(sessionID string) *SessionData {
 				OrderNumbers:  []string{},
 				CardNumbers:   []string{},
 				IFSCCodes:     []string{},
+				OfficerIDs:    []string{},
+				SkypeIDs:      []string{},
 			},
 			CurrentState:            StateInit,
 			QuestionsAsked:          0,
 			InvestigativeQuestions:  0,
 			RedFlagsIdentified:      []string{},
 			InformationElicitations: 0,
+			ActCounts:               map[DialogueAct]int{},
 		},
 		MessageHistory: []string{},
+		Transcript:     []ChatTurn{},
 		Keywords:       []string{},
+		Persona:        persona,
+		Facts:          FactLedger{Facts: PersonaFacts(persona)},
+		Selection:      NewTemplateSelection(SessionSeed(sessionID)),
 		LastUpdated:    time.Now(),
 		StartTime:      time.Now(),
+		turnMu:         &sync.Mutex{},
 	}
 
 	s.sessions[sessionID] = newSession
@@ -90,9 +112,60 @@ func (s *SessionStore) Delete(sessionID string) {
 	delete(s.sessions, sessionID)
 }
 
-// AddMessage appends a message to the session history
+// Finish removes a session after its final callback and refuses it for
+// finishedTTL. Sessions closed longer ago are forgotten.
+func (s *SessionStore) Finish(sessionID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for id, closed := range s.finished {
+		if now.Sub(closed) >= finishedTTL {
+			delete(s.finished, id)
+		}
+	}
+	delete(s.sessions, sessionID)
+	s.finished[sessionID] = now
+}
+
+// Finished reports whether the session was closed by Finish within finishedTTL
+func (s *SessionStore) Finished(sessionID string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	closed, ok := s.finished[sessionID]
+	return ok && time.Since(closed) < finishedTTL
+}
+
+// BeginTurn claims the session for one turn. Concurrent requests for the same
+// session (e.g. platform retries) wait for the current turn to finish.
+func (session *SessionData) BeginTurn() {
+	session.turnMu.Lock()
+}
+
+// EndTurn releases the session after a turn
+func (session *SessionData) EndTurn() {
+	session.turnMu.Unlock()
+}
+
+// AddMessage appends a scammer message to the session history
 func (session *SessionData) AddMessage(text string) {
 	session.MessageHistory = append(session.MessageHistory, text)
+	session.Transcript = append(session.Transcript, ChatTurn{Sender: SenderScammer, Text: text})
+}
+
+// AddReply records the agent's own reply in the transcript
+func (session *SessionData) AddReply(text string) {
+	session.Transcript = append(session.Transcript, ChatTurn{Sender: SenderAgent, Text: text})
+}
+
+// SeedTranscript restores earlier turns supplied by the platform when the
+// session has no transcript of its own yet (e.g. after a restart)
+func (session *SessionData) SeedTranscript(turns []ChatTurn) {
+	if len(session.Transcript) > 0 {
+		return
+	}
+	session.Transcript = append(session.Transcript, turns...)
 }
 
 // AddKeyword adds a suspicious keyword (avoiding duplicates)
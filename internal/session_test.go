@@ -0,0 +1,28 @@
+package internal
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFinishedSessionsExpire(t *testing.T) {
+	s := &SessionStore{sessions: map[string]*SessionData{}, finished: map[string]time.Time{}}
+	s.Get("old")
+	s.Finish("old")
+	if !s.Finished("old") {
+		t.Fatal("session not finished right after Finish")
+	}
+	if _, ok := s.sessions["old"]; ok {
+		t.Error("Finish kept the session")
+	}
+
+	// Past finishedTTL the ID is free again and forgotten on the next Finish
+	s.finished["old"] = time.Now().Add(-finishedTTL)
+	if s.Finished("old") {
+		t.Error("session still refused after finishedTTL")
+	}
+	s.Finish("new")
+	if _, ok := s.finished["old"]; ok || len(s.finished) != 1 {
+		t.Errorf("finished = %v, want only the new session", s.finished)
+	}
+}
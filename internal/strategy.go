@@ -0,0 +1,333 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Strategy learning closes the loop between what we ask and what scammers
+// hand over. Every reply records its scam type, intent and template; the
+// scammer's next message rewards that choice when it brings new intel.
+// Thompson sampling over these counts orders the templates of an intent and,
+// in policy states marked "learn", the intel goals still missing.
+
+// StrategyChoice is what one reply did. Intent-level statistics use an empty
+// Template.
+type StrategyChoice struct {
+	ScamType string `json:"scamType"`
+	Intent   Intent `json:"intent"`
+	Template string `json:"template,omitempty"` // Catalogue key (locale/id), "llm" for generated replies
+}
+
+// TemplateLLM marks replies written by the LLM rather than taken from the catalogue
+const TemplateLLM = "llm"
+
+// StrategyArm is a choice with how often it was made and paid off
+type StrategyArm struct {
+	StrategyChoice
+	Pulls   int     `json:"pulls"`
+	Rewards int     `json:"rewards"`
+	Rate    float64 `json:"rate"` // Rewards / pulls, informational
+}
+
+// sample draws a plausible intel rate from Beta(1+rewards, 1+misses)
+func (a *StrategyArm) sample(r *rand.Rand) float64 {
+	if a == nil {
+		return r.Float64()
+	}
+	x := gammaSample(float64(1+a.Rewards), r)
+	y := gammaSample(float64(1+a.Pulls-a.Rewards), r)
+	return x / (x + y)
+}
+
+// gammaSample draws from Gamma(shape, 1) for shape >= 1 (Marsaglia and Tsang)
+func gammaSample(shape float64, r *rand.Rand) float64 {
+	d := shape - 1.0/3
+	c := 1 / math.Sqrt(9*d)
+	for {
+		x := r.NormFloat64()
+		v := 1 + c*x
+		if v <= 0 {
+			continue
+		}
+		v = v * v * v
+		u := r.Float64()
+		if math.Log(u) < 0.5*x*x+d-d*v+d*math.Log(v) {
+			return d * v
+		}
+	}
+}
+
+// StrategyReport is the persisted and inspectable form of the statistics
+type StrategyReport struct {
+	Learning  bool          `json:"learning"`
+	File      string        `json:"file,omitempty"`
+	Updated   time.Time     `json:"updated"`
+	Intents   []StrategyArm `json:"intents"`
+	Templates []StrategyArm `json:"templates"`
+}
+
+// StrategyStats holds the learned arms. It is shared by all sessions.
+type StrategyStats struct {
+	mu      sync.RWMutex
+	arms    map[StrategyChoice]*StrategyArm
+	updated time.Time
+
+	saveMu sync.Mutex
+	path   string // "" keeps the statistics in memory only
+
+	learning bool // Use the statistics to order choices, not only record them
+	warmup   int  // Pulls every goal needs before goal order is learned
+}
+
+var (
+	strategy     *StrategyStats
+	strategyOnce sync.Once
+	strategyErr  error
+)
+
+// LoadStrategy reads the learned statistics from STRATEGY_STATS_FILE
+// (default strategy_stats.json, "off" to keep them in memory).
+//
+//	STRATEGY_LEARNING      use the statistics when choosing (default true)
+//	STRATEGY_WARMUP_PULLS  asks per intel goal before its order is learned (default 20)
+func LoadStrategy() error {
+	strategyOnce.Do(func() {
+		strategy = strategyFromEnv()
+		strategyErr = strategy.load()
+		if strategyErr == nil {
+			log.Printf("Loaded strategy statistics: %d arms (learning %v)", len(strategy.arms), strategy.learning)
+		}
+	})
+	return strategyErr
+}
+
+// strategyFromEnv returns empty statistics configured as LoadStrategy describes
+func strategyFromEnv() *StrategyStats {
+	path := os.Getenv("STRATEGY_STATS_FILE")
+	switch path {
+	case "":
+		path = "strategy_stats.json"
+	case "off":
+		path = ""
+	}
+	learning := true
+	if v := os.Getenv("STRATEGY_LEARNING"); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			learning = b
+		}
+	}
+	return &StrategyStats{
+		arms:     map[StrategyChoice]*StrategyArm{},
+		path:     path,
+		learning: learning,
+		warmup:   envInt("STRATEGY_WARMUP_PULLS", 20),
+	}
+}
+
+// GetStrategy returns the shared statistics, loading them on first use
+func GetStrategy() *StrategyStats {
+	if err := LoadStrategy(); err != nil {
+		log.Printf("Strategy statistics failed to load, starting empty: %v", err)
+	}
+	return strategy
+}
+
+func (s *StrategyStats) load() error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var report StrategyReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return err
+	}
+	for _, list := range [][]StrategyArm{report.Intents, report.Templates} {
+		for _, arm := range list {
+			if arm.Pulls < arm.Rewards || arm.Rewards < 0 {
+				return errors.New("arm " + arm.ScamType + "/" + string(arm.Intent) + " has more rewards than pulls")
+			}
+			a := arm
+			s.arms[a.StrategyChoice] = &a
+		}
+	}
+	s.updated = report.Updated
+	return nil
+}
+
+// Record counts the choice made by a reply and whether the scammer's next
+// message brought new intel, then persists the statistics
+func (s *StrategyStats) Record(choice StrategyChoice, rewarded bool) {
+	choices := []StrategyChoice{{ScamType: choice.ScamType, Intent: choice.Intent}}
+	if choice.Template != "" {
+		choices = append(choices, choice)
+	}
+
+	s.mu.Lock()
+	for _, c := range choices {
+		arm := s.arms[c]
+		if arm == nil {
+			arm = &StrategyArm{StrategyChoice: c}
+			s.arms[c] = arm
+		}
+		arm.Pulls++
+		if rewarded {
+			arm.Rewards++
+		}
+		arm.Rate = float64(arm.Rewards) / float64(arm.Pulls)
+	}
+	s.updated = time.Now()
+	s.mu.Unlock()
+
+	if err := s.save(); err != nil {
+		log.Printf("Saving strategy statistics failed: %v", err)
+	}
+}
+
+// Report returns the statistics, best intel rate first within each intent
+func (s *StrategyStats) Report() StrategyReport {
+	s.mu.RLock()
+	report := StrategyReport{Learning: s.learning, File: s.path, Updated: s.updated}
+	for _, arm := range s.arms {
+		if arm.Template == "" {
+			report.Intents = append(report.Intents, *arm)
+		} else {
+			report.Templates = append(report.Templates, *arm)
+		}
+	}
+	s.mu.RUnlock()
+
+	for _, list := range [][]StrategyArm{report.Intents, report.Templates} {
+		sort.Slice(list, func(i, j int) bool {
+			a, b := list[i], list[j]
+			if a.ScamType != b.ScamType {
+				return a.ScamType < b.ScamType
+			}
+			if a.Intent != b.Intent {
+				return a.Intent < b.Intent
+			}
+			if a.Rate != b.Rate {
+				return a.Rate > b.Rate
+			}
+			return a.Template < b.Template
+		})
+	}
+	return report
+}
+
+// save writes the statistics to a temporary file and renames it over the old
+// one so a crash never leaves a truncated file behind
+func (s *StrategyStats) save() error {
+	if s.path == "" {
+		return nil
+	}
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+
+	data, err := json.MarshalIndent(s.Report(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, data)
+}
+
+// writeFileAtomic replaces the file at path with data through a temporary file
+// in the same directory
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *StrategyStats) arm(c StrategyChoice) *StrategyArm {
+	if a := s.arms[c]; a != nil {
+		copied := *a
+		return &copied
+	}
+	return nil
+}
+
+// orderTemplates ranks templates by a sampled intel rate raised to
+// 1/weight, so templates without statistics keep their weighted random order
+// and proven ones move to the front
+func (s *StrategyStats) orderTemplates(scamType string, templates []*ResponseTemplate, r *rand.Rand) []*ResponseTemplate {
+	if s == nil || !s.learning || scamType == "" {
+		return weightedOrder(templates, r)
+	}
+	s.mu.RLock()
+	arms := make([]*StrategyArm, len(templates))
+	for i, t := range templates {
+		arms[i] = s.arm(StrategyChoice{ScamType: scamType, Intent: t.Intent, Template: t.key()})
+	}
+	s.mu.RUnlock()
+
+	keys := make(map[*ResponseTemplate]float64, len(templates))
+	for i, t := range templates {
+		keys[t] = math.Pow(arms[i].sample(r), 1/t.Weight)
+	}
+	order := append([]*ResponseTemplate(nil), templates...)
+	sort.SliceStable(order, func(i, j int) bool { return keys[order[i]] > keys[order[j]] })
+	return order
+}
+
+// orderGoals ranks the intents chasing missing intel by sampled intel rate.
+// Only goals tried at least warmup times for the scam type are reordered,
+// among the positions they hold; the others keep the policy's order.
+func (s *StrategyStats) orderGoals(scamType string, intents []Intent, r *rand.Rand) []Intent {
+	if s == nil || !s.learning || len(intents) < 2 {
+		return intents
+	}
+	s.mu.RLock()
+	arms := make([]*StrategyArm, len(intents))
+	for i, intent := range intents {
+		arms[i] = s.arm(StrategyChoice{ScamType: scamType, Intent: intent})
+	}
+	s.mu.RUnlock()
+
+	var slots []int
+	var learned []Intent
+	keys := make(map[Intent]float64, len(intents))
+	for i, arm := range arms {
+		if arm == nil || arm.Pulls < s.warmup {
+			continue
+		}
+		slots = append(slots, i)
+		learned = append(learned, intents[i])
+		keys[intents[i]] = arm.sample(r)
+	}
+	if len(learned) < 2 {
+		return intents
+	}
+	sort.SliceStable(learned, func(i, j int) bool { return keys[learned[i]] > keys[learned[j]] })
+	order := append([]Intent(nil), intents...)
+	for n, slot := range slots {
+		order[slot] = learned[n]
+	}
+	return order
+}
@@ -0,0 +1,93 @@
+package internal
+
+import (
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStrategyPersistsAcrossRestarts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "strategy.json")
+	t.Setenv("STRATEGY_STATS_FILE", path)
+	t.Setenv("STRATEGY_LEARNING", "")
+
+	s := strategyFromEnv()
+	if err := s.load(); err != nil {
+		t.Fatalf("load without a file: %v", err)
+	}
+	upi := StrategyChoice{ScamType: "bank_fraud", Intent: IntentAskUPI, Template: "en/ask_upi_01"}
+	s.Record(upi, true)
+	s.Record(upi, false)
+	s.Record(StrategyChoice{ScamType: "bank_fraud", Intent: IntentAskUPI, Template: TemplateLLM}, true)
+	s.Record(StrategyChoice{ScamType: "bank_fraud", Intent: IntentStall}, false)
+
+	restarted := strategyFromEnv()
+	if err := restarted.load(); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	for choice, want := range map[StrategyChoice][2]int{
+		{ScamType: "bank_fraud", Intent: IntentAskUPI}: {3, 2},
+		upi: {2, 1},
+		{ScamType: "bank_fraud", Intent: IntentAskUPI, Template: TemplateLLM}:  {1, 1},
+		{ScamType: "bank_fraud", Intent: IntentStall}:                          {1, 0},
+		{ScamType: "bank_fraud", Intent: IntentStall, Template: "en/stall_01"}: {0, 0},
+	} {
+		arm := restarted.arm(choice)
+		var got [2]int
+		if arm != nil {
+			got = [2]int{arm.Pulls, arm.Rewards}
+		}
+		if got != want {
+			t.Errorf("%+v: pulls and rewards %v after a restart, want %v", choice, got, want)
+		}
+	}
+	if report := restarted.Report(); len(report.Intents) != 2 || len(report.Templates) != 2 || report.Templates[0].Rate != 1 {
+		t.Errorf("report = %+v, want 2 intent and 2 template arms, best rate first", report)
+	}
+}
+
+func TestStrategyRejectsInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "strategy.json")
+	t.Setenv("STRATEGY_STATS_FILE", path)
+	for name, data := range map[string]string{
+		"malformed":               `{"intents": [`,
+		"more rewards than pulls": `{"intents": [{"scamType": "bank_fraud", "intent": "ASK_UPI", "pulls": 1, "rewards": 2}]}`,
+	} {
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := strategyFromEnv().load(); err == nil {
+			t.Errorf("%s: file accepted", name)
+		}
+	}
+}
+
+func TestOrderGoalsLearnsAfterWarmup(t *testing.T) {
+	t.Setenv("STRATEGY_STATS_FILE", "off")
+	t.Setenv("STRATEGY_WARMUP_PULLS", "10")
+	s := strategyFromEnv()
+	goals := []Intent{IntentAskPhone, IntentAskBank, IntentAskUPI}
+	r := rand.New(rand.NewSource(3))
+
+	// Below the warmup the policy's order stands
+	for i := 0; i < 9; i++ {
+		s.Record(StrategyChoice{ScamType: "bank_fraud", Intent: IntentAskUPI}, true)
+		s.Record(StrategyChoice{ScamType: "bank_fraud", Intent: IntentAskPhone}, false)
+	}
+	if got := s.orderGoals("bank_fraud", goals, r); got[0] != IntentAskPhone {
+		t.Errorf("order before the warmup = %v", got)
+	}
+
+	for i := 0; i < 20; i++ {
+		s.Record(StrategyChoice{ScamType: "bank_fraud", Intent: IntentAskUPI}, true)
+		s.Record(StrategyChoice{ScamType: "bank_fraud", Intent: IntentAskPhone}, false)
+	}
+	got := s.orderGoals("bank_fraud", goals, r)
+	if got[0] != IntentAskUPI || got[1] != IntentAskBank || got[2] != IntentAskPhone {
+		t.Errorf("order after the warmup = %v, want UPI in the phone's slot and bank untouched", got)
+	}
+	if other := s.orderGoals("lottery_fraud", goals, r); other[0] != IntentAskPhone {
+		t.Errorf("statistics of bank_fraud reordered lottery_fraud: %v", other)
+	}
+}
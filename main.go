@@ -4,9 +4,12 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/muskiteer/Ai-Scam/internal"
 	"github.com/muskiteer/Ai-Scam/middleware"
 	"github.com/muskiteer/Ai-Scam/routes"
 )
@@ -26,6 +29,44 @@ func main() {
 		port = "8080"
 	}
 
+	// Validate prompt templates before accepting traffic
+	if err := internal.LoadPrompts(); err != nil {
+		log.Fatalf("Invalid prompt templates: %v", err)
+	}
+	if err := internal.LoadResponses(); err != nil {
+		log.Fatalf("Invalid response catalogue: %v", err)
+	}
+	if err := internal.LoadPolicies(); err != nil {
+		log.Fatalf("Invalid planning policy: %v", err)
+	}
+	if err := internal.LoadRules(); err != nil {
+		log.Fatalf("Invalid detection rules: %v", err)
+	}
+	if err := internal.LoadClassifier(); err != nil {
+		log.Fatalf("Invalid classifier model: %v", err)
+	}
+	// Refuse to start rather than overwrite learned statistics, issued
+	// honeytokens or canaries we cannot read
+	if err := internal.LoadStrategy(); err != nil {
+		log.Fatalf("Invalid strategy statistics: %v", err)
+	}
+	if err := internal.LoadHoneytokens(); err != nil {
+		log.Fatalf("Invalid honeytoken registry: %v", err)
+	}
+	if err := internal.LoadCanaries(); err != nil {
+		log.Fatalf("Invalid canary registry: %v", err)
+	}
+
+	// Reload prompts, responses, policies, detection rules, the classifier and other file based configuration on SIGHUP
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			log.Println("SIGHUP received, reloading configuration")
+			internal.ReloadAll()
+		}
+	}()
+
 	// Setup routes
 	mux := http.NewServeMux()
 	routes.SetupRoutes(mux)
@@ -8,4 +8,10 @@ import (
 func SetupRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("/health", handler.HealthCheck)
 	mux.HandleFunc("/api/engage", handler.StartConvo)
+	mux.HandleFunc("/api/analyze", handler.Analyze)
+	mux.HandleFunc("/api/admin/reload", handler.ReloadConfig)
+	mux.HandleFunc("/api/admin/strategy", handler.StrategyStats)
+	mux.HandleFunc("/api/admin/honeytokens", handler.Honeytokens)
+	mux.HandleFunc("/api/admin/canaries", handler.Canaries)
+	mux.HandleFunc("/c/", handler.Canary)
 }